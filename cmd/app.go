@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/floriansw/hll-geofences/data"
+	"github.com/floriansw/hll-geofences/history"
+	"github.com/floriansw/hll-geofences/worker"
+)
+
+// app ties the config, the state and the workers together. It implements control.Controller.
+type app struct {
+	l          *slog.Logger
+	ctx        context.Context
+	configPath string
+	state      *data.State
+	m          *worker.Manager
+
+	// activated is notified whenever a profile was activated.
+	activated chan struct{}
+
+	mu     sync.Mutex
+	config *data.Config
+	// profilePath is the path of the config of the active profile.
+	profilePath string
+}
+
+func newApp(ctx context.Context, l *slog.Logger, configPath string, state *data.State, r history.Recorder) *app {
+	return &app{
+		l:          l,
+		ctx:        ctx,
+		configPath: configPath,
+		state:      state,
+		m:          worker.NewManager(l, r, state.Paused...),
+		activated:  make(chan struct{}, 1),
+	}
+}
+
+// Reload reads the config and the config of the active profile, and applies the servers of the latter. The previous
+// config is kept when reading fails.
+func (a *app) Reload() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	c, err := data.NewConfig(a.configPath, a.l)
+	if err != nil {
+		return err
+	}
+	profile := a.state.Profile
+	if profile == "" {
+		profile = data.DefaultProfile
+	}
+	p, err := c.Profile(profile, a.l)
+	if errors.Is(err, data.ErrUnknownProfile) {
+		a.l.Warn("profile-removed", "profile", profile)
+		a.state.Profile = ""
+		p, err = c, a.state.Save()
+	}
+	if err != nil {
+		return err
+	}
+	a.config, a.profilePath = c, p.Path()
+	a.m.Apply(a.ctx, p.Servers)
+	return nil
+}
+
+// WatchPaths returns the paths of the config and of the config of the active profile, which are watched for changes.
+func (a *app) WatchPaths() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.profilePath == "" || a.profilePath == a.configPath {
+		return []string{a.configPath}
+	}
+	return []string{a.configPath, a.profilePath}
+}
+
+func (a *app) Stop() {
+	a.m.Stop()
+}
+
+func (a *app) Status() []worker.Status {
+	return a.m.Status()
+}
+
+func (a *app) Health() []worker.Health {
+	return a.m.Health()
+}
+
+func (a *app) SetEnabled(address string, enabled bool) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.m.SetEnabled(address, enabled); err != nil {
+		return err
+	}
+	a.state.SetPaused(address, !enabled)
+	return a.state.Save()
+}
+
+func (a *app) Profiles() (string, []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	active := a.state.Profile
+	if active == "" {
+		active = data.DefaultProfile
+	}
+	return active, a.config.ProfileNames()
+}
+
+func (a *app) ActivateProfile(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p, err := a.config.Profile(name, a.l)
+	if err != nil {
+		return err
+	}
+	a.m.Apply(a.ctx, p.Servers)
+	a.state.Profile, a.profilePath = name, p.Path()
+	a.l.Info("activate-profile", "profile", name)
+	select {
+	case a.activated <- struct{}{}:
+	default:
+	}
+	return a.state.Save()
+}
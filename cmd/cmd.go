@@ -2,19 +2,38 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 
-	"github.com/floriansw/go-hll-rcon/rconv2"
+	"github.com/floriansw/hll-geofences/control"
 	"github.com/floriansw/hll-geofences/data"
-	"github.com/floriansw/hll-geofences/worker"
+	"github.com/floriansw/hll-geofences/history"
 	"github.com/joho/godotenv"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "generate":
+			generate(os.Args[2:])
+			return
+		case "validate":
+			validate(os.Args[2:])
+			return
+		case "history":
+			queryHistory(os.Args[2:])
+			return
+		}
+	}
+	run()
+}
 
+func run() {
 	if err := godotenv.Load(); err != nil {
 		slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})).Warn("load-env", "error", err)
 	}
@@ -31,39 +50,89 @@ func main() {
 		configPath = path
 	}
 
-	c, err := data.NewConfig(configPath, logger)
+	statePath := "./state.yml"
+	if path, ok := os.LookupEnv("STATE_PATH"); ok {
+		statePath = path
+	}
+
+	state, err := data.NewState(statePath, logger)
 	if err != nil {
-		logger.Error("config", err)
+		logger.Error("state", "error", err)
 		return
 	}
 
-	defer func() {
-		err = c.Save()
+	// the history is recorded unless HISTORY_PATH is explicitly set to an empty value
+	var recorder history.Recorder
+	if path := historyPath(); path != "" {
+		store, err := history.NewStore(path)
 		if err != nil {
-			logger.Error("save-config", err)
+			logger.Error("history", "error", err)
+			return
 		}
-	}()
+		defer store.Close()
+		recorder = store
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
-	for _, server := range c.Servers {
-		pool, err := rconv2.NewConnectionPool(rconv2.ConnectionPoolOptions{
-			Logger:   logger,
-			Hostname: server.Host,
-			Port:     server.Port,
-			Password: server.Password,
-		})
-		if err != nil {
-			logger.Error("create-connection-pool", "server", server.Host, "error", err)
-			continue
-		}
-		w := worker.NewWorker(logger, pool, server)
-		go w.Run(ctx)
+	a := newApp(ctx, logger, configPath, state, recorder)
+	if err := a.Reload(); err != nil {
+		logger.Error("config", "error", err)
+		cancel()
+		return
+	}
+
+	if addr, ok := os.LookupEnv("HTTP_ADDR"); ok {
+		s := &http.Server{Addr: addr, Handler: control.NewServer(logger, a, os.Getenv("HTTP_TOKEN"))}
+		go func() {
+			if err := s.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("http-server", "error", err)
+			}
+		}()
+		defer s.Close()
 	}
 
+	// the config of the active profile is watched as well, and the watch is replaced when another profile is active
+	paths := a.WatchPaths()
+	changes, stopWatching := watch(ctx, paths, logger)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
-	<-stop
+	for {
+		select {
+		case <-stop:
+			logger.Info("graceful-shutdown")
+			a.Stop()
+			cancel()
+			return
+		case <-reload:
+			reloadConfig(a, logger)
+		case <-changes:
+			reloadConfig(a, logger)
+		case <-a.activated:
+		}
+		if p := a.WatchPaths(); !slices.Equal(p, paths) {
+			stopWatching()
+			paths = p
+			changes, stopWatching = watch(ctx, paths, logger)
+		}
+	}
+}
 
-	logger.Info("graceful-shutdown")
-	cancel()
+func reloadConfig(a *app, logger *slog.Logger) {
+	logger.Info("reload-config")
+	if err := a.Reload(); err != nil {
+		logger.Error("reload-config", "error", err)
+	}
+}
+
+// watch watches the configs at paths for changes until the returned function is called. Changes are not noticed, when
+// the configs cannot be watched.
+func watch(ctx context.Context, paths []string, logger *slog.Logger) (<-chan struct{}, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	changes, err := data.Watch(ctx, paths, logger)
+	if err != nil {
+		logger.Warn("watch-config", "error", err)
+	}
+	return changes, cancel
 }
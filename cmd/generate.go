@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/floriansw/hll-geofences/data"
+)
+
+// generate writes a config for a common seeding layout to stdout or the file passed with -output.
+func generate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	layout := fs.String("layout", string(data.LayoutMidcap), fmt.Sprintf("The seeding layout, one of: %s, %s, %s", data.LayoutMidcap, data.LayoutStrip, data.LayoutLastLinesBlocked))
+	maps := fs.String("maps", "", fmt.Sprintf("Comma separated list of maps to generate fences for, all known maps when empty: %s", strings.Join(data.KnownMaps(), ", ")))
+	mode := fs.String("mode", "Warfare", "The game mode the fences apply to")
+	players := fs.Int("players", 50, "The number of players from which on the fences do not apply anymore")
+	width := fs.Int("width", 2, fmt.Sprintf("The number of lines of the strip in the middle of the map for the %s layout", data.LayoutStrip))
+	lines := fs.Int("lines", 2, fmt.Sprintf("The number of the enemy's last lines which are blocked for the %s layout", data.LayoutLastLinesBlocked))
+	host := fs.String("host", "SERVER-IP", "The IP address of the game server")
+	port := fs.Int("port", 7779, "The RCON port of the game server")
+	password := fs.String("password", "RCON-PW", "The RCON password of the game server")
+	punishAfter := fs.Int("punish-after", 10, "The number of seconds a player can be outside of a fence before getting punished")
+	output := fs.String("output", "", "The file to write the config to, stdout when empty")
+	_ = fs.Parse(args)
+
+	var m []string
+	for _, name := range strings.Split(*maps, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			m = append(m, strings.ToUpper(name))
+		}
+	}
+	c, err := data.Generate(data.GenerateOptions{
+		Layout:      data.Layout(*layout),
+		Maps:        m,
+		GameMode:    *mode,
+		PlayerCount: *players,
+		Width:       *width,
+		Lines:       *lines,
+		Server: data.Server{
+			Host:               *host,
+			Port:               *port,
+			Password:           *password,
+			PunishAfterSeconds: punishAfter,
+		},
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "generate:", err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		out, err := c.Marshal()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "generate:", err)
+			os.Exit(1)
+		}
+		_, _ = os.Stdout.Write(out)
+		return
+	}
+	if err := c.SaveTo(*output); err != nil {
+		fmt.Fprintln(os.Stderr, "generate:", err)
+		os.Exit(1)
+	}
+}
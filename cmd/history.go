@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/floriansw/hll-geofences/history"
+)
+
+func historyPath() string {
+	if path, ok := os.LookupEnv("HISTORY_PATH"); ok {
+		return path
+	}
+	return "./history.jsonl"
+}
+
+// queryHistory prints the recorded events matching the given flags, oldest first.
+func queryHistory(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	path := fs.String("path", historyPath(), "The path of the history file")
+	player := fs.String("player", "", "Only events of the player with this ID or name")
+	mapName := fs.String("map", "", "Only events on this map, e.g., FOY")
+	server := fs.String("server", "", "Only events of the server with this host:port")
+	since := fs.String("since", "", "Only events since this time: a date (2006-01-02), a time (2006-01-02T15:04:05Z07:00) or a duration ago (24h)")
+	until := fs.String("until", "", "Only events before this time, in the same format as -since")
+	_ = fs.Parse(args)
+
+	f := history.Filter{Player: *player, MapName: *mapName, Server: *server}
+	var err error
+	if f.Since, err = parseTime(*since, time.Now()); err != nil {
+		fmt.Fprintln(os.Stderr, "history: since:", err)
+		os.Exit(1)
+	}
+	if f.Until, err = parseTime(*until, time.Now()); err != nil {
+		fmt.Fprintln(os.Stderr, "history: until:", err)
+		os.Exit(1)
+	}
+
+	events, err := history.Query(*path, f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "history:", err)
+		os.Exit(1)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tEVENT\tSERVER\tMAP\tPLAYER\tPLAYER ID\tTEAM\tGRID\tPOSITION")
+	for _, e := range events {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%.0f,%.0f,%.0f\n",
+			e.Time.Local().Format(time.DateTime), e.Type, e.Server, e.MapName, e.PlayerName, e.PlayerID, e.Team, e.Grid,
+			e.Position.X, e.Position.Y, e.Position.Z)
+	}
+	_ = tw.Flush()
+}
+
+// parseTime parses s as a date or time in the local time zone, or as a duration before now. The zero time is returned
+// when s is empty.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a date, a time or a duration", s)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/floriansw/hll-geofences/data"
+)
+
+// validate checks the config passed as the first argument, or the one in CONFIG_PATH, and reports each finding
+// with its line. It exits with a non-zero status code when there are findings, which are not warnings.
+func validate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	_ = fs.Parse(args)
+
+	configPath := "./config.yml"
+	if path, ok := os.LookupEnv("CONFIG_PATH"); ok {
+		configPath = path
+	}
+	if fs.NArg() > 0 {
+		configPath = fs.Arg(0)
+	}
+
+	raw, err := os.ReadFile(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "validate:", err)
+		os.Exit(1)
+	}
+	failed := false
+	for _, f := range data.Validate(raw) {
+		if f.Warning {
+			fmt.Printf("%s:%d: warning: %s\n", configPath, f.Line, f.Message)
+			continue
+		}
+		fmt.Printf("%s:%d: %s\n", configPath, f.Line, f.Message)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+	fmt.Printf("%s: ok\n", configPath)
+}
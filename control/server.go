@@ -0,0 +1,146 @@
+package control
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/floriansw/hll-geofences/data"
+	"github.com/floriansw/hll-geofences/metrics"
+	"github.com/floriansw/hll-geofences/worker"
+)
+
+// Controller is what the control API operates on.
+type Controller interface {
+	Status() []worker.Status
+	Health() []worker.Health
+	SetEnabled(address string, enabled bool) error
+	// Profiles returns the name of the active profile and the names of all available profiles.
+	Profiles() (string, []string)
+	ActivateProfile(name string) error
+}
+
+type Server struct {
+	l     *slog.Logger
+	c     Controller
+	token string
+	mux   *http.ServeMux
+}
+
+// NewServer creates the HTTP control API. Requests changing the state need to pass token as a bearer token in the
+// Authorization header. They are not served at all when token is empty, so that exposing the metrics and health
+// checks does not expose the enforcement of the fences.
+func NewServer(l *slog.Logger, c Controller, token string) *Server {
+	s := &Server{l: l, c: c, token: token, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /servers", s.servers)
+	s.mux.HandleFunc("GET /profiles", s.profiles)
+	if token != "" {
+		s.mux.HandleFunc("POST /servers/{address}/pause", s.authorized(s.setEnabled(false)))
+		s.mux.HandleFunc("POST /servers/{address}/resume", s.authorized(s.setEnabled(true)))
+		s.mux.HandleFunc("POST /profiles/{name}/activate", s.authorized(s.activateProfile))
+	} else {
+		l.Info("control-read-only", "reason", "HTTP_TOKEN is not set")
+	}
+	s.mux.Handle("GET /metrics", metrics.Default.Handler())
+	s.mux.HandleFunc("GET /healthz", s.health(func(h worker.Health) bool { return h.Healthy }))
+	s.mux.HandleFunc("GET /readyz", s.health(func(h worker.Health) bool { return h.Ready }))
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+			s.error(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) servers(w http.ResponseWriter, _ *http.Request) {
+	status := s.c.Status()
+	if status == nil {
+		status = []worker.Status{}
+	}
+	s.json(w, http.StatusOK, status)
+}
+
+func (s *Server) setEnabled(enabled bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := s.c.SetEnabled(r.PathValue("address"), enabled)
+		if errors.Is(err, worker.ErrUnknownServer) {
+			s.error(w, http.StatusNotFound, err)
+			return
+		} else if err != nil {
+			s.error(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type healthResponse struct {
+	Status  string          `json:"status"`
+	Servers []worker.Health `json:"servers"`
+}
+
+// health responds with the health of all servers, and fails when ok is false for any of them.
+func (s *Server) health(ok func(worker.Health) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		res := healthResponse{Status: "ok", Servers: s.c.Health()}
+		status := http.StatusOK
+		for _, h := range res.Servers {
+			if !ok(h) {
+				res.Status, status = "failing", http.StatusServiceUnavailable
+			}
+		}
+		if res.Servers == nil {
+			res.Servers = []worker.Health{}
+		}
+		s.json(w, status, res)
+	}
+}
+
+type profilesResponse struct {
+	Active    string   `json:"active"`
+	Available []string `json:"available"`
+}
+
+func (s *Server) profiles(w http.ResponseWriter, _ *http.Request) {
+	active, available := s.c.Profiles()
+	s.json(w, http.StatusOK, profilesResponse{Active: active, Available: available})
+}
+
+func (s *Server) activateProfile(w http.ResponseWriter, r *http.Request) {
+	err := s.c.ActivateProfile(r.PathValue("name"))
+	if errors.Is(err, data.ErrUnknownProfile) {
+		s.error(w, http.StatusNotFound, err)
+		return
+	} else if err != nil {
+		s.error(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) error(w http.ResponseWriter, status int, err error) {
+	s.json(w, status, errorResponse{Error: err.Error()})
+}
+
+func (s *Server) json(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.l.Error("write-response", "error", err)
+	}
+}
@@ -0,0 +1,202 @@
+package control_test
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/floriansw/hll-geofences/control"
+	"github.com/floriansw/hll-geofences/data"
+	"github.com/floriansw/hll-geofences/worker"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeController struct {
+	status  []worker.Status
+	health  []worker.Health
+	active  string
+	enabled map[string]bool
+}
+
+func (f *fakeController) Status() []worker.Status {
+	return f.status
+}
+
+func (f *fakeController) Health() []worker.Health {
+	return f.health
+}
+
+func (f *fakeController) SetEnabled(address string, enabled bool) error {
+	if _, ok := f.enabled[address]; !ok {
+		return fmt.Errorf("%w: %s", worker.ErrUnknownServer, address)
+	}
+	f.enabled[address] = enabled
+	return nil
+}
+
+func (f *fakeController) Profiles() (string, []string) {
+	return f.active, []string{data.DefaultProfile, "extended"}
+}
+
+func (f *fakeController) ActivateProfile(name string) error {
+	if name != data.DefaultProfile && name != "extended" {
+		return fmt.Errorf("%w: %s", data.ErrUnknownProfile, name)
+	}
+	f.active = name
+	return nil
+}
+
+var _ = Describe("Server", func() {
+	var c *fakeController
+	var s *httptest.Server
+	var token string
+
+	BeforeEach(func() {
+		token = "secret"
+		c = &fakeController{
+			status:  []worker.Status{{Address: "127.0.0.1:7779", Enabled: true, MapName: "CARENTAN", PlayerCount: 20}},
+			active:  data.DefaultProfile,
+			enabled: map[string]bool{"127.0.0.1:7779": true},
+		}
+	})
+
+	JustBeforeEach(func() {
+		s = httptest.NewServer(control.NewServer(slog.New(slog.NewTextHandler(os.Stdout, nil)), c, token))
+	})
+
+	AfterEach(func() {
+		s.Close()
+	})
+
+	request := func(method, path, authorization string) (int, string) {
+		req, err := http.NewRequest(method, s.URL+path, nil)
+		Expect(err).ToNot(HaveOccurred())
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		res, err := http.DefaultClient.Do(req)
+		Expect(err).ToNot(HaveOccurred())
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		Expect(err).ToNot(HaveOccurred())
+		return res.StatusCode, string(body)
+	}
+
+	It("lists the status of the servers", func() {
+		code, body := request(http.MethodGet, "/servers", "")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(MatchJSON(`[{"address":"127.0.0.1:7779","enabled":true,"map_name":"CARENTAN","player_count":20,"axis_fences":0,"allies_fences":0,"players_outside":0}]`))
+	})
+
+	It("pauses and resumes a server", func() {
+		code, _ := request(http.MethodPost, "/servers/127.0.0.1:7779/pause", "Bearer secret")
+		Expect(code).To(Equal(http.StatusNoContent))
+		Expect(c.enabled["127.0.0.1:7779"]).To(BeFalse())
+
+		code, _ = request(http.MethodPost, "/servers/127.0.0.1:7779/resume", "Bearer secret")
+		Expect(code).To(Equal(http.StatusNoContent))
+		Expect(c.enabled["127.0.0.1:7779"]).To(BeTrue())
+	})
+
+	It("returns not found for unknown servers", func() {
+		code, body := request(http.MethodPost, "/servers/127.0.0.1:1/pause", "Bearer secret")
+		Expect(code).To(Equal(http.StatusNotFound))
+		Expect(body).To(MatchJSON(`{"error":"unknown server: 127.0.0.1:1"}`))
+	})
+
+	It("lists and activates profiles", func() {
+		code, _ := request(http.MethodPost, "/profiles/extended/activate", "Bearer secret")
+		Expect(code).To(Equal(http.StatusNoContent))
+
+		code, body := request(http.MethodGet, "/profiles", "")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(MatchJSON(`{"active":"extended","available":["default","extended"]}`))
+	})
+
+	It("returns not found for unknown profiles", func() {
+		code, _ := request(http.MethodPost, "/profiles/unknown/activate", "Bearer secret")
+		Expect(code).To(Equal(http.StatusNotFound))
+		Expect(c.active).To(Equal(data.DefaultProfile))
+	})
+
+	It("serves the metrics", func() {
+		code, body := request(http.MethodGet, "/metrics", "")
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(body).To(ContainSubstring("# TYPE hll_geofences_warnings_sent_total counter"))
+	})
+
+	Context("health", func() {
+		BeforeEach(func() {
+			c.health = []worker.Health{
+				{Address: "127.0.0.1:7779", Started: true, Connected: true, Healthy: true, Ready: true},
+				{Address: "127.0.0.1:7780", ConsecutiveErrors: 1, Healthy: true, LastError: "connection refused"},
+			}
+		})
+
+		It("is healthy when all servers are healthy", func() {
+			code, body := request(http.MethodGet, "/healthz", "")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(ContainSubstring(`"status":"ok"`))
+			Expect(body).To(ContainSubstring(`"last_error":"connection refused"`))
+		})
+
+		It("is not ready when a server never started", func() {
+			code, body := request(http.MethodGet, "/readyz", "")
+			Expect(code).To(Equal(http.StatusServiceUnavailable))
+			Expect(body).To(ContainSubstring(`"status":"failing"`))
+		})
+
+		It("is unhealthy when a server is unhealthy", func() {
+			c.health[1].Healthy = false
+			code, _ := request(http.MethodGet, "/healthz", "")
+			Expect(code).To(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("is ready without servers", func() {
+			c.health = nil
+			code, body := request(http.MethodGet, "/readyz", "")
+			Expect(code).To(Equal(http.StatusOK))
+			Expect(body).To(MatchJSON(`{"status":"ok","servers":[]}`))
+		})
+	})
+
+	It("rejects changes without token", func() {
+		code, _ := request(http.MethodPost, "/servers/127.0.0.1:7779/pause", "")
+		Expect(code).To(Equal(http.StatusUnauthorized))
+		code, _ = request(http.MethodPost, "/profiles/extended/activate", "Bearer wrong")
+		Expect(code).To(Equal(http.StatusUnauthorized))
+		Expect(c.enabled["127.0.0.1:7779"]).To(BeTrue())
+		Expect(c.active).To(Equal(data.DefaultProfile))
+	})
+
+	It("allows reading the status without token", func() {
+		code, _ := request(http.MethodGet, "/servers", "")
+		Expect(code).To(Equal(http.StatusOK))
+	})
+
+	Context("without token", func() {
+		BeforeEach(func() {
+			token = ""
+		})
+
+		It("does not serve changes", func() {
+			code, _ := request(http.MethodPost, "/servers/127.0.0.1:7779/pause", "")
+			Expect(code).To(Equal(http.StatusNotFound))
+			code, _ = request(http.MethodPost, "/profiles/extended/activate", "")
+			Expect(code).To(Equal(http.StatusNotFound))
+			Expect(c.enabled["127.0.0.1:7779"]).To(BeTrue())
+			Expect(c.active).To(Equal(data.DefaultProfile))
+		})
+
+		It("serves the status, metrics and health checks", func() {
+			for _, path := range []string{"/servers", "/profiles", "/metrics", "/healthz"} {
+				code, _ := request(http.MethodGet, path, "")
+				Expect(code).To(Equal(http.StatusOK), path)
+			}
+		})
+	})
+})
@@ -0,0 +1,195 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/floriansw/go-hll-rcon/rconv2/api"
+	"gopkg.in/yaml.v3"
+)
+
+// GameState is the state of a game, which the conditions of fences are evaluated against.
+type GameState struct {
+	Session *api.GetSessionResponse
+	// AxisPlayers and AlliesPlayers are the number of players on each side, according to the last poll of the players.
+	AxisPlayers   int
+	AlliesPlayers int
+	// Time is the current time in the time zone of the server.
+	Time time.Time
+}
+
+func (s GameState) session() api.GetSessionResponse {
+	if s.Session == nil {
+		return api.GetSessionResponse{}
+	}
+	return *s.Session
+}
+
+// textKeys are the condition keys of Equals and NotEquals, together with their value in a game state.
+var textKeys = map[string]func(GameState) string{
+	"map_name":    func(s GameState) string { return s.session().MapName },
+	"game_mode":   func(s GameState) string { return s.session().GameMode },
+	"server_name": func(s GameState) string { return s.session().ServerName },
+}
+
+// numberKeys are the condition keys of LessThan, GreaterThan and Between, together with their value in a game state.
+var numberKeys = map[string]func(GameState) int{
+	"player_count":        func(s GameState) int { return s.session().PlayerCount },
+	"max_player_count":    func(s GameState) int { return s.session().MaxPlayerCount },
+	"queue_count":         func(s GameState) int { return s.session().QueueCount },
+	"vip_queue_count":     func(s GameState) int { return s.session().VIPQueueCount },
+	"axis_player_count":   func(s GameState) int { return s.AxisPlayers },
+	"allies_player_count": func(s GameState) int { return s.AlliesPlayers },
+	// team_imbalance is the difference of the number of players of both sides
+	"team_imbalance": func(s GameState) int { return max(s.AxisPlayers-s.AlliesPlayers, s.AlliesPlayers-s.AxisPlayers) },
+}
+
+// conditionKeys are the keys each operator of a Condition understands.
+var conditionKeys = map[string][]string{
+	"Equals":      sortedKeys(textKeys),
+	"NotEquals":   sortedKeys(textKeys),
+	"LessThan":    sortedKeys(numberKeys),
+	"GreaterThan": sortedKeys(numberKeys),
+	"Between":     sortedKeys(numberKeys),
+}
+
+func (f Fence) Matches(s GameState) (bool, error) {
+	return f.MatchesWithin(s, 0)
+}
+
+// MatchesWithin is like Matches, but the thresholds of LessThan, GreaterThan and Between are widened by band, so that
+// a fence, which applies already, keeps applying until the game state is band beyond a threshold.
+func (f Fence) MatchesWithin(s GameState, band int) (bool, error) {
+	for _, c := range f.inherited {
+		if ok, err := c.widen(band).Matches(s); !ok || err != nil {
+			return false, err
+		}
+	}
+	if f.Condition == nil {
+		return true, nil
+	}
+	return f.Condition.widen(band).Matches(s)
+}
+
+type Condition struct {
+	Equals    map[string][]string `yaml:"Equals,omitempty"`
+	NotEquals map[string][]string `yaml:"NotEquals,omitempty"`
+	LessThan  map[string]int      `yaml:"LessThan,omitempty"`
+	// GreaterThan is the same as LessThan, just that it matches when the value of the game state is greater.
+	GreaterThan map[string]int `yaml:"GreaterThan,omitempty"`
+	// Between matches when the value of the game state is within the lower and upper bound, both inclusive.
+	Between map[string][]int `yaml:"Between,omitempty"`
+	// Schedule matches when the time of the game state is within any of the windows.
+	Schedule []TimeWindow `yaml:"Schedule,omitempty"`
+
+	// ref is the name of the condition in Config.Conditions, when the condition is a reference to a named condition
+	ref string
+}
+
+// UnmarshalYAML accepts either a condition, or the name of a condition in Config.Conditions.
+func (c *Condition) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*c = Condition{ref: value.Value}
+		return nil
+	}
+	type condition Condition
+	return value.Decode((*condition)(c))
+}
+
+func (c Condition) MarshalYAML() (interface{}, error) {
+	if c.ref != "" {
+		return c.ref, nil
+	}
+	type condition Condition
+	return condition(c), nil
+}
+
+// Matches reports whether the game state s matches each key of each operator of the condition. Unknown keys,
+// invalid bounds of Between and invalid windows of the Schedule are an error instead of a condition never matching.
+func (c Condition) Matches(s GameState) (bool, error) {
+	if err := c.check(); err != nil {
+		return false, err
+	}
+	for k, v := range c.Equals {
+		if !slices.Contains(v, textKeys[k](s)) {
+			return false, nil
+		}
+	}
+	for k, v := range c.NotEquals {
+		if slices.Contains(v, textKeys[k](s)) {
+			return false, nil
+		}
+	}
+	for k, v := range c.LessThan {
+		if numberKeys[k](s) >= v {
+			return false, nil
+		}
+	}
+	for k, v := range c.GreaterThan {
+		if numberKeys[k](s) <= v {
+			return false, nil
+		}
+	}
+	for k, v := range c.Between {
+		if n := numberKeys[k](s); n < v[0] || n > v[1] {
+			return false, nil
+		}
+	}
+	if len(c.Schedule) == 0 {
+		return true, nil
+	}
+	for _, w := range c.Schedule {
+		if ok, err := w.Includes(s.Time); ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// widen returns the condition with the thresholds of LessThan, GreaterThan and Between moved outwards by band.
+func (c Condition) widen(band int) Condition {
+	if band == 0 {
+		return c
+	}
+	w := c
+	w.LessThan, w.GreaterThan, w.Between = map[string]int{}, map[string]int{}, map[string][]int{}
+	for k, v := range c.LessThan {
+		w.LessThan[k] = v + band
+	}
+	for k, v := range c.GreaterThan {
+		w.GreaterThan[k] = v - band
+	}
+	for k, v := range c.Between {
+		// invalid bounds are kept, so that they fail the check of the condition
+		if len(v) == 2 && v[0] <= v[1] {
+			v = []int{v[0] - band, v[1] + band}
+		}
+		w.Between[k] = v
+	}
+	return w
+}
+
+// check returns an error for the first unknown key or invalid bounds of the condition.
+func (c Condition) check() error {
+	for operator, keys := range map[string][]string{
+		"Equals":      sortedKeys(c.Equals),
+		"NotEquals":   sortedKeys(c.NotEquals),
+		"LessThan":    sortedKeys(c.LessThan),
+		"GreaterThan": sortedKeys(c.GreaterThan),
+		"Between":     sortedKeys(c.Between),
+	} {
+		for _, k := range keys {
+			if !slices.Contains(conditionKeys[operator], k) {
+				return fmt.Errorf("unknown condition key %s for %s, expected one of %s", k, operator, strings.Join(conditionKeys[operator], ", "))
+			}
+		}
+	}
+	for k, v := range c.Between {
+		if len(v) != 2 || v[0] > v[1] {
+			return fmt.Errorf("invalid bounds %v of %s for Between, expected a lower and an upper bound", v, k)
+		}
+	}
+	return nil
+}
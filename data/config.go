@@ -1,73 +1,175 @@
 package data
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
+	"maps"
+	"net"
 	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
+	"time"
 
 	"github.com/floriansw/go-hll-rcon/rconv2/api"
 	"gopkg.in/yaml.v3"
 )
 
 type Fence struct {
-	X         *string    `yaml:"X,omitempty"`
-	Y         *int       `yaml:"Y,omitempty"`
-	Numpads   []int      `yaml:"Numpad,omitempty"`
-	Condition *Condition `yaml:"Condition,omitempty"`
+	X         *string     `yaml:"X,omitempty"`
+	Y         *int        `yaml:"Y,omitempty"`
+	Numpads   []int       `yaml:"Numpad,omitempty"`
+	From      *GridRef    `yaml:"From,omitempty"`
+	To        *GridRef    `yaml:"To,omitempty"`
+	Grids     []GridRange `yaml:"Grids,omitempty"`
+	Polygon   []Point     `yaml:"Polygon,omitempty"`
+	Radius    *Radius     `yaml:"Radius,omitempty"`
+	Deny      bool        `yaml:"Deny,omitempty"`
+	Group     *string     `yaml:"Group,omitempty"`
+	Condition *Condition  `yaml:"Condition,omitempty"`
+	// Settings override the ones of the server and the side for players leaving this fence, or entering it when it
+	// is a Deny fence.
+	Settings `yaml:",inline"`
+
+	// members are the fences of the referenced Group, once resolved
+	members []Fence
+	// inherited are conditions of the group and the fence referencing the group this fence is a member of
+	inherited []*Condition
 }
 
-func (f Fence) Includes(w api.Grid) bool {
-	if f.X != nil && w.X != *f.X {
-		return false
+// Violates reports whether a player at world position p violates the given fences. This is the case when the player
+// is inside any Deny fence, or when the player is inside none of the allowed fences, if there are any.
+func Violates(fences []Fence, si *api.GetSessionResponse, p api.WorldPosition) bool {
+	v, _ := Evaluate(fences, si, p)
+	return v
+}
+
+// Evaluate is Violates, which additionally returns the fence deciding about the violation: the Deny fence the player
+// is inside of, or the allowed fence the player is inside of. It is nil when the player is outside of all fences.
+func Evaluate(fences []Fence, si *api.GetSessionResponse, p api.WorldPosition) (bool, *Fence) {
+	allowed := false
+	var inside *Fence
+	for i, f := range fences {
+		if f.Deny {
+			if f.Contains(si, p) {
+				return true, &fences[i]
+			}
+			continue
+		}
+		allowed = true
+		if inside == nil && f.Contains(si, p) {
+			inside = &fences[i]
+		}
 	}
-	if f.Y != nil && w.Y != *f.Y {
-		return false
+	return allowed && inside == nil, inside
+}
+
+// Point is a vertex of a polygon fence in world coordinates, the same unit as api.WorldPosition.
+type Point struct {
+	X float64 `yaml:"X"`
+	Y float64 `yaml:"Y"`
+}
+
+// Radius describes a circular fence around a centre, given either in world coordinates (X and Y) or as a grid
+// reference (e.g. E5) with an optional Numpad. Without a Numpad, the centre of the grid is used.
+type Radius struct {
+	X      *float64 `yaml:"X,omitempty"`
+	Y      *float64 `yaml:"Y,omitempty"`
+	Grid   *string  `yaml:"Grid,omitempty"`
+	Numpad *int     `yaml:"Numpad,omitempty"`
+	Meters float64  `yaml:"Meters"`
+}
+
+// Center returns the world position of the centre of the circle on the map of the given session. It returns false
+// if the centre cannot be resolved, e.g. because the grid reference is invalid or the map geometry is unknown.
+func (r Radius) Center(si *api.GetSessionResponse) (api.WorldPosition, bool) {
+	if r.Grid == nil {
+		if r.X == nil || r.Y == nil {
+			return api.WorldPosition{}, false
+		}
+		return api.WorldPosition{X: *r.X, Y: *r.Y}, true
 	}
-	if len(f.Numpads) == 0 {
-		return true
+	x, y, err := parseGrid(*r.Grid)
+	if err != nil {
+		return api.WorldPosition{}, false
 	}
-	return slices.Contains(f.Numpads, w.Numpad)
+	numpad := 5
+	if r.Numpad != nil {
+		numpad = *r.Numpad
+	}
+	return gridCenter(si, x, y, numpad)
 }
 
-func (f Fence) Matches(si *api.GetSessionResponse) bool {
-	if f.Condition == nil {
-		return true
+// Includes reports whether p is within Meters of the centre. The height of p is ignored, the circle extends
+// infinitely on the vertical axis.
+func (r Radius) Includes(si *api.GetSessionResponse, p api.WorldPosition) bool {
+	c, ok := r.Center(si)
+	if !ok {
+		return false
 	}
-	return f.Condition.Matches(si)
+	c.Z = p.Z
+	return p.Distance(c).Meters() <= r.Meters
 }
 
-type Condition struct {
-	Equals      map[string][]string `yaml:"Equals,omitempty"`
-	LessThan    map[string]int      `yaml:"LessThan,omitempty"`
-	GreaterThan map[string]int      `yaml:"GreaterThan,omitempty"`
+// Contains reports whether a player at world position p is inside the fence on the map of the given session. Polygon
+// and Radius fences are checked against the raw world position, all other fences against the grid of p.
+func (f Fence) Contains(si *api.GetSessionResponse, p api.WorldPosition) bool {
+	if len(f.Polygon) != 0 {
+		return f.polygonIncludes(p)
+	}
+	if f.Radius != nil {
+		return f.Radius.Includes(si, p)
+	}
+	return f.Includes(GridOf(si, p))
 }
 
-func (c Condition) Matches(si *api.GetSessionResponse) bool {
-	if len(c.Equals) == 0 && len(c.LessThan) == 0 && len(c.GreaterThan) == 0 {
-		return true
+// polygonIncludes implements the even-odd rule: a ray cast from p crosses the polygon edges an odd number of times
+// when p is inside the polygon.
+func (f Fence) polygonIncludes(p api.WorldPosition) bool {
+	if len(f.Polygon) < 3 {
+		return false
 	}
-	for k, v := range c.Equals {
-		if k == "map_name" && slices.Contains(v, si.MapName) {
-			continue
-		}
-		if k == "game_mode" && slices.Contains(v, si.GameMode) {
-			continue
+	in := false
+	for i, j := 0, len(f.Polygon)-1; i < len(f.Polygon); j, i = i, i+1 {
+		a, b := f.Polygon[i], f.Polygon[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			in = !in
 		}
+	}
+	return in
+}
+
+func (f Fence) Includes(w api.Grid) bool {
+	if f.X != nil && w.X != *f.X {
 		return false
 	}
-	for k, v := range c.LessThan {
-		if k == "player_count" && si.PlayerCount < v {
-			continue
-		}
+	if f.Y != nil && w.Y != *f.Y {
 		return false
 	}
-	for k, v := range c.GreaterThan {
-		if k == "player_count" && si.PlayerCount > v {
-			continue
-		}
+	if (f.From != nil || f.To != nil) && !f.fromTo().Includes(w) {
+		return false
+	}
+	if len(f.Grids) != 0 && !slices.ContainsFunc(f.Grids, func(r GridRange) bool { return r.Includes(w) }) {
 		return false
 	}
-	return true
+	if len(f.Numpads) == 0 {
+		return true
+	}
+	return slices.Contains(f.Numpads, w.Numpad)
+}
+
+// fromTo returns the rectangle spanned by From and To. A missing From or To extends the rectangle to the
+// respective corner of the map.
+func (f Fence) fromTo() GridRange {
+	r := GridRange{From: GridRef{X: "A", Y: 1}, To: GridRef{X: "J", Y: 10}}
+	if f.From != nil {
+		r.From = *f.From
+	}
+	if f.To != nil {
+		r.To = *f.To
+	}
+	return r
 }
 
 type Server struct {
@@ -78,9 +180,111 @@ type Server struct {
 	AxisFence          []Fence   `yaml:"AxisFence"`
 	AlliesFence        []Fence   `yaml:"AlliesFence"`
 	Messages           *Messages `yaml:"Messages,omitempty"`
+	// GERFence to B8AFence replace the fences of the side for the players of one faction, e.g., on maps where the
+	// factions of a side differ.
+	GERFence []Fence `yaml:"GERFence,omitempty"`
+	USFence  []Fence `yaml:"USFence,omitempty"`
+	RUSFence []Fence `yaml:"RUSFence,omitempty"`
+	GBFence  []Fence `yaml:"GBFence,omitempty"`
+	DAKFence []Fence `yaml:"DAKFence,omitempty"`
+	B8AFence []Fence `yaml:"B8AFence,omitempty"`
+	// Factions overrides the side the players of a faction are checked as.
+	Factions map[Faction]Side `yaml:"Factions,omitempty"`
+	// AttackerFence and DefenderFence replace the fences of the sides while an Offensive is played, for the side
+	// attacking and the side defending respectively.
+	AttackerFence []Fence `yaml:"AttackerFence,omitempty"`
+	DefenderFence []Fence `yaml:"DefenderFence,omitempty"`
+	// TimeZone is the IANA time zone, e.g., Europe/Berlin, which the schedules of conditions are evaluated in. It is
+	// UTC when not set.
+	TimeZone string `yaml:"TimeZone,omitempty"`
+	// OffensiveAttackers is the side attacking in the Offensive of a map, by map name. The Allies attack on maps not
+	// listed.
+	OffensiveAttackers map[string]Side `yaml:"OffensiveAttackers,omitempty"`
+	// AxisSettings and AlliesSettings override PunishAfterSeconds and Messages for the players of one side.
+	AxisSettings   *Settings `yaml:"AxisSettings,omitempty"`
+	AlliesSettings *Settings `yaml:"AlliesSettings,omitempty"`
+	// Warnings is the schedule of the messages sent to a player outside of the fences before the player is punished.
+	// A single warning is sent as soon as the player left the fences when it is empty.
+	Warnings []Warning `yaml:"Warnings,omitempty"`
+	// Escalation configures the action taken against players depending on how often they left the play area before.
+	// Players are always punished when it is not set.
+	Escalation *Escalation `yaml:"Escalation,omitempty"`
+	// Hysteresis stabilises the applicable fences around the thresholds of their conditions.
+	Hysteresis *Hysteresis `yaml:"Hysteresis,omitempty"`
+
+	// overrides are the messages of the side and the fence, once applied with For
+	overrides Messages
+}
+
+type Side string
+
+const (
+	SideAxis   Side = "Axis"
+	SideAllies Side = "Allies"
+)
+
+// Settings override the punishment settings of a server for a side or a fence.
+type Settings struct {
+	PunishAfterSeconds *int      `yaml:"PunishAfterSeconds,omitempty"`
+	Messages           *Messages `yaml:"Messages,omitempty"`
+}
+
+// or returns the settings, where each setting, which is not set, is taken from o.
+func (s Settings) or(o *Settings) Settings {
+	if o == nil {
+		return s
+	}
+	if s.PunishAfterSeconds == nil {
+		s.PunishAfterSeconds = o.PunishAfterSeconds
+	}
+	if s.Messages == nil {
+		s.Messages = o.Messages
+	} else if o.Messages != nil {
+		m := s.Messages.or(*o.Messages)
+		s.Messages = &m
+	}
+	return s
+}
+
+// For returns the server with the settings applying to a player of side, who left the fence f (or entered it, when
+// it is a Deny fence). The settings of the fence take precedence over the ones of the side, which take precedence
+// over the ones of the server and the environment. f is nil when the player was not inside of any fence before.
+func (s Server) For(side Side, f *Fence) Server {
+	var o Settings
+	if f != nil {
+		o = f.Settings
+	}
+	if side == SideAxis {
+		o = o.or(s.AxisSettings)
+	} else if side == SideAllies {
+		o = o.or(s.AlliesSettings)
+	}
+	if o.PunishAfterSeconds != nil {
+		s.PunishAfterSeconds = o.PunishAfterSeconds
+	}
+	if o.Messages != nil {
+		s.overrides = *o.Messages
+	}
+	return s
+}
+
+// Address returns the host and RCON port of the server, which identifies the server in the config.
+func (s Server) Address() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// PunishAfter returns the duration a player can be outside of the fences before getting punished.
+func (s Server) PunishAfter() time.Duration {
+	if s.PunishAfterSeconds == nil {
+		return 10 * time.Second
+	}
+	return time.Duration(*s.PunishAfterSeconds) * time.Second
 }
 
 func (s Server) WarningMessage() string {
+	if s.overrides.Warning != nil {
+		return *s.overrides.Warning
+	}
 	if warning := os.Getenv("WARNING_MESSAGE"); warning != "" {
 		return warning
 	}
@@ -91,6 +295,9 @@ func (s Server) WarningMessage() string {
 }
 
 func (s Server) PunishMessage() string {
+	if s.overrides.Punish != nil {
+		return *s.overrides.Punish
+	}
 	if punish := os.Getenv("PUNISH_MESSAGE"); punish != "" {
 		return punish
 	}
@@ -100,37 +307,138 @@ func (s Server) PunishMessage() string {
 	return *s.Messages.Punish
 }
 
+// BackInsideMessage returns the message sent to a player who returned into the fences before being punished. No
+// message is sent when it is empty.
+func (s Server) BackInsideMessage() string {
+	if s.overrides.BackInside != nil {
+		return *s.overrides.BackInside
+	}
+	if back := os.Getenv("BACK_INSIDE_MESSAGE"); back != "" {
+		return back
+	}
+	if s.Messages == nil || s.Messages.BackInside == nil {
+		return ""
+	}
+	return *s.Messages.BackInside
+}
+
+// WarningSchedule returns the Warnings of the server sorted by the time they are sent, each with the message to send.
+// Warnings without a Message use the WarningMessage of the server. The Warning message of a side or a fence applied
+// with For replaces the messages of all warnings.
+func (s Server) WarningSchedule() []Warning {
+	warnings := slices.Clone(s.Warnings)
+	if len(warnings) == 0 {
+		warnings = []Warning{{AfterSeconds: 0}}
+	}
+	for i, w := range warnings {
+		if w.Message == nil || s.overrides.Warning != nil {
+			m := s.WarningMessage()
+			warnings[i].Message = &m
+		}
+	}
+	slices.SortStableFunc(warnings, func(a, b Warning) int {
+		return a.AfterSeconds - b.AfterSeconds
+	})
+	return warnings
+}
+
 type Messages struct {
-	Warning *string `yaml:"Warning,omitempty"`
-	Punish  *string `yaml:"Punish,omitempty"`
+	Warning    *string `yaml:"Warning,omitempty"`
+	Punish     *string `yaml:"Punish,omitempty"`
+	BackInside *string `yaml:"BackInside,omitempty"`
+}
+
+// or returns the messages, where each message, which is not set, is taken from o.
+func (m Messages) or(o Messages) Messages {
+	if m.Warning == nil {
+		m.Warning = o.Warning
+	}
+	if m.Punish == nil {
+		m.Punish = o.Punish
+	}
+	if m.BackInside == nil {
+		m.BackInside = o.BackInside
+	}
+	return m
+}
+
+// Warning is a message sent to a player AfterSeconds after the player left the fences. The Message is formatted with
+// the time remaining until the player is punished.
+type Warning struct {
+	AfterSeconds int     `yaml:"AfterSeconds"`
+	Message      *string `yaml:"Message,omitempty"`
 }
 
 type Config struct {
-	Servers []Server `yaml:"Servers"`
-	path    string
+	// Profiles are the paths of other configs, which can be activated at runtime instead of this config. Relative
+	// paths are relative to the directory of this config.
+	Profiles    map[string]string     `yaml:"Profiles,omitempty"`
+	Conditions  map[string]Condition  `yaml:"Conditions,omitempty"`
+	FenceGroups map[string]FenceGroup `yaml:"FenceGroups,omitempty"`
+	Servers     []Server              `yaml:"Servers"`
+	path        string
+}
+
+// ErrUnknownProfile is returned when a profile is not in the config.
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// DefaultProfile is the name of the profile of the config itself.
+const DefaultProfile = "default"
+
+// Path returns the path the config was read from.
+func (c *Config) Path() string {
+	return c.path
+}
+
+// ProfileNames returns the DefaultProfile followed by the names of all other profiles, sorted by name.
+func (c *Config) ProfileNames() []string {
+	return append([]string{DefaultProfile}, slices.Sorted(maps.Keys(c.Profiles))...)
 }
 
+// Profile reads the config of the profile with the given name. The DefaultProfile returns the config itself.
+func (c *Config) Profile(name string, logger *slog.Logger) (*Config, error) {
+	if name == DefaultProfile {
+		return c, nil
+	}
+	path, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(c.path), path)
+	}
+	return NewConfig(path, logger)
+}
+
+// Marshal returns the YAML representation of the config, as it is written by Save.
+func (c *Config) Marshal() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+// Save writes the config back to the file it was read from. The config is never saved implicitly, as saving drops all
+// comments of the file.
 func (c *Config) Save() error {
-	config, err := yaml.Marshal(c)
+	return c.SaveTo(c.path)
+}
+
+// SaveTo writes the config to path, replacing the file atomically.
+func (c *Config) SaveTo(path string) error {
+	config, err := c.Marshal()
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(c.path, config, 0655)
+	return writeFileAtomic(path, config)
 }
 
+// NewConfig reads the config from path. The file is only read, never written.
 func NewConfig(path string, logger *slog.Logger) (*Config, error) {
-	config, err := readConfig(path, logger)
-	if err != nil {
-		return config, err
-	}
-
-	return config, config.Save()
+	return readConfig(path, logger)
 }
 
 func readConfig(path string, logger *slog.Logger) (*Config, error) {
 	var config Config
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		logger.Info("create-config")
+		logger.Warn("config-not-found", "path", path)
 		config = Config{}
 	} else {
 		logger.Info("read-existing-config")
@@ -138,11 +446,22 @@ func readConfig(path string, logger *slog.Logger) (*Config, error) {
 		if err != nil {
 			return &Config{}, err
 		}
+		var errs ValidationError
+		for _, f := range Validate(c) {
+			if f.Warning {
+				logger.Warn("config-warning", "path", path, "line", f.Line, "message", f.Message)
+			} else {
+				errs = append(errs, f)
+			}
+		}
+		if len(errs) != 0 {
+			return &Config{}, errs
+		}
 		err = yaml.Unmarshal(c, &config)
 		if err != nil {
 			return &Config{}, err
 		}
 	}
 	config.path = path
-	return &config, nil
+	return &config, config.resolve()
 }
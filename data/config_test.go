@@ -6,8 +6,10 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
 	"log/slog"
 	"os"
+	"time"
 )
 
 var _ = Describe("Config", func() {
@@ -30,6 +32,245 @@ var _ = Describe("Config", func() {
 			c, err = data.NewConfig(f.Name(), l)
 			Expect(err).ToNot(HaveOccurred())
 		})
+
+		It("does not modify the config when reading it", func() {
+			l := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			f, err := os.CreateTemp(os.TempDir(), "config")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.Remove(f.Name())
+			config := "# A comment of the operator\nServers: [] # another comment\n"
+			Expect(os.WriteFile(f.Name(), []byte(config), 0640)).To(Succeed())
+
+			_, err = data.NewConfig(f.Name(), l)
+			Expect(err).ToNot(HaveOccurred())
+
+			content, err := os.ReadFile(f.Name())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(content)).To(Equal(config))
+		})
+
+		It("saves atomically and keeps the permissions of the config", func() {
+			l := slog.New(slog.NewTextHandler(os.Stdout, nil))
+			dir, err := os.MkdirTemp(os.TempDir(), "config")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			path := dir + "/config.yml"
+			Expect(os.WriteFile(path, []byte("Servers: []"), 0640)).To(Succeed())
+
+			c, err := data.NewConfig(path, l)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.Save()).To(Succeed())
+
+			fi, err := os.Stat(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0640)))
+			entries, err := os.ReadDir(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+		})
+
+		It("saves new configs only accessible by the owner", func() {
+			dir, err := os.MkdirTemp(os.TempDir(), "config")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			path := dir + "/config.yml"
+
+			Expect((&data.Config{}).SaveTo(path)).To(Succeed())
+
+			fi, err := os.Stat(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fi.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+	})
+
+	Describe("References", func() {
+		var l *slog.Logger
+		var path string
+
+		BeforeEach(func() {
+			l = slog.New(slog.NewTextHandler(os.Stdout, nil))
+			f, err := os.CreateTemp(os.TempDir(), "config")
+			Expect(err).ToNot(HaveOccurred())
+			path = f.Name()
+		})
+
+		AfterEach(func() {
+			Expect(os.Remove(path)).To(Succeed())
+		})
+
+		write := func(config string) {
+			Expect(os.WriteFile(path, []byte(config), 0600)).To(Succeed())
+		}
+
+		seeding := data.GameState{Session: &api.GetSessionResponse{MapName: "CARENTAN", GameMode: "Warfare", PlayerCount: 20}}
+		live := data.GameState{Session: &api.GetSessionResponse{MapName: "CARENTAN", GameMode: "Warfare", PlayerCount: 80}}
+
+		It("resolves named conditions and fence groups", func() {
+			write(`
+Conditions:
+  seeding:
+    LessThan:
+      player_count: 50
+  carentan:
+    Equals:
+      map_name: [CARENTAN]
+FenceGroups:
+  midcap:
+    Condition: seeding
+    Fences:
+      - X: E
+      - X: F
+        Condition: carentan
+Servers:
+  - Host: 127.0.0.1
+    Port: 7779
+    AxisFence:
+      - Group: midcap
+      - X: A
+        Condition: seeding
+    AlliesFence:
+      - Group: midcap
+        Condition: carentan
+`)
+			c, err := data.NewConfig(path, l)
+			Expect(err).ToNot(HaveOccurred())
+
+			axis := c.Servers[0].AxisFence
+			Expect(axis[0].Expand()).To(HaveLen(2))
+			Expect(axis[0].Expand()[0].Matches(seeding)).To(BeTrue())
+			Expect(axis[0].Expand()[0].Matches(live)).To(BeFalse())
+			Expect(axis[0].Expand()[1].Matches(seeding)).To(BeTrue())
+			Expect(axis[0].Expand()[1].Matches(data.GameState{Session: &api.GetSessionResponse{MapName: "FOY", GameMode: "Warfare", PlayerCount: 20}})).To(BeFalse())
+			Expect(axis[1].Expand()).To(Equal([]data.Fence{axis[1]}))
+			Expect(axis[1].Matches(seeding)).To(BeTrue())
+			Expect(axis[1].Matches(live)).To(BeFalse())
+
+			allies := c.Servers[0].AlliesFence
+			Expect(allies[0].Expand()[0].Matches(seeding)).To(BeTrue())
+			Expect(allies[0].Expand()[0].Matches(data.GameState{Session: &api.GetSessionResponse{MapName: "FOY", GameMode: "Warfare", PlayerCount: 20}})).To(BeFalse())
+		})
+
+		It("keeps references when saving", func() {
+			write(`
+Conditions:
+  seeding:
+    LessThan:
+      player_count: 50
+FenceGroups:
+  midcap:
+    Fences:
+      - X: E
+Servers:
+  - Host: 127.0.0.1
+    Port: 7779
+    AxisFence:
+      - Group: midcap
+        Condition: seeding
+`)
+			c, err := data.NewConfig(path, l)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.Save()).To(Succeed())
+
+			saved, err := os.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(saved)).To(ContainSubstring("Group: midcap"))
+			Expect(string(saved)).To(ContainSubstring("Condition: seeding"))
+		})
+
+		It("applies the settings of the referencing fence to the fence group", func() {
+			write(`
+FenceGroups:
+  midcap:
+    Fences:
+      - X: E
+      - X: F
+        PunishAfterSeconds: 5
+Servers:
+  - Host: 127.0.0.1
+    Port: 7779
+    AxisFence:
+      - Group: midcap
+        PunishAfterSeconds: 20
+        Messages:
+          Warning: edge of the mid strip
+`)
+			c, err := data.NewConfig(path, l)
+			Expect(err).ToNot(HaveOccurred())
+
+			fences := c.Servers[0].AxisFence[0].Expand()
+			Expect(fences[0].PunishAfterSeconds).To(Equal(Pointer(20)))
+			Expect(fences[0].Messages.Warning).To(Equal(Pointer("edge of the mid strip")))
+			Expect(fences[1].PunishAfterSeconds).To(Equal(Pointer(5)))
+			Expect(fences[1].Messages.Warning).To(Equal(Pointer("edge of the mid strip")))
+		})
+
+		It("resolves fence groups in the fences of a faction", func() {
+			write(`
+FenceGroups:
+  midcap:
+    Fences:
+      - X: E
+Servers:
+  - Host: 127.0.0.1
+    Port: 7779
+    GBFence:
+      - Group: midcap
+`)
+			c, err := data.NewConfig(path, l)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.Servers[0].GBFence[0].Expand()).To(HaveLen(1))
+			Expect(c.Servers[0].GBFence[0].Expand()[0].X).To(Equal(Pointer("E")))
+		})
+
+		It("fails on unknown map names", func() {
+			write(`
+Servers:
+  - Host: 127.0.0.1
+    Port: 7779
+    AxisFence:
+      - X: A
+        Condition:
+          Equals:
+            map_name: [CARENTEN]
+`)
+			_, err := data.NewConfig(path, l)
+			Expect(err).To(MatchError("invalid config: line 9: unknown map name CARENTEN"))
+		})
+
+		DescribeTable("fails on unknown references", func(config, message string) {
+			write(config)
+			_, err := data.NewConfig(path, l)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		},
+			Entry("unknown condition in fence", `
+Servers:
+  - Host: 127.0.0.1
+    Port: 7779
+    AxisFence:
+      - X: A
+        Condition: seeding
+`, "line 7: unknown condition seeding"),
+			Entry("unknown fence group", `
+Servers:
+  - Host: 127.0.0.1
+    Port: 7779
+    AlliesFence:
+      - Group: midcap
+`, "line 6: unknown fence group midcap"),
+			Entry("unknown condition in fence group", `
+FenceGroups:
+  midcap:
+    Condition: seeding
+    Fences:
+      - X: E
+`, "line 4: unknown condition seeding"),
+			Entry("nested fence groups", `
+FenceGroups:
+  midcap:
+    Fences:
+      - Group: other
+`, "line 5: fence group midcap cannot reference another fence group"),
+		)
 	})
 
 	Describe("Fence", func() {
@@ -70,6 +311,177 @@ var _ = Describe("Config", func() {
 					Y: Pointer(5),
 				}.Includes(api.Grid{X: "G", Y: 5, Numpad: 7})).To(BeTrue())
 			})
+
+			DescribeTable("grid ranges", func(config string, g api.Grid, expected bool) {
+				var f data.Fence
+				Expect(yaml.Unmarshal([]byte(config), &f)).To(Succeed())
+				Expect(f.Includes(g)).To(Equal(expected))
+			},
+				Entry("within From and To", "{From: C3, To: H8}", api.Grid{X: "D", Y: 8, Numpad: 1}, true),
+				Entry("outside From and To", "{From: C3, To: H8}", api.Grid{X: "B", Y: 4, Numpad: 1}, false),
+				Entry("From only extends to the map corner", "{From: C3}", api.Grid{X: "J", Y: 10, Numpad: 1}, true),
+				Entry("To only extends to the map corner", "{To: C3}", api.Grid{X: "D", Y: 1, Numpad: 1}, false),
+				Entry("within a range of Grids", "{Grids: [C3-H8, A2]}", api.Grid{X: "H", Y: 3, Numpad: 1}, true),
+				Entry("within a single grid of Grids", "{Grids: [C3-H8, A2]}", api.Grid{X: "A", Y: 2, Numpad: 1}, true),
+				Entry("outside of Grids", "{Grids: [C3-H8, A2]}", api.Grid{X: "A", Y: 3, Numpad: 1}, false),
+				Entry("within a reversed range", "{Grids: [H8-C3]}", api.Grid{X: "E", Y: 5, Numpad: 1}, true),
+				Entry("limited by numpad", "{Grids: [C3-H8], Numpad: [1]}", api.Grid{X: "E", Y: 5, Numpad: 2}, false),
+				Entry("limited by X", "{Grids: [C3-H8], X: E}", api.Grid{X: "F", Y: 5, Numpad: 1}, false),
+			)
+
+			DescribeTable("invalid grid ranges", func(config string) {
+				var f data.Fence
+				Expect(yaml.Unmarshal([]byte(config), &f)).ToNot(Succeed())
+			},
+				Entry("unknown column", "{From: K3}"),
+				Entry("unknown row", "{To: A11}"),
+				Entry("invalid range", "{Grids: [C3-]}"),
+				Entry("missing row", "{Grids: [C]}"),
+			)
+
+			It("marshals grid ranges back to their short form", func() {
+				var f data.Fence
+				Expect(yaml.Unmarshal([]byte("{From: C3, Grids: [C3-H8, A2]}"), &f)).To(Succeed())
+				out, err := yaml.Marshal(f)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(out)).To(Equal("From: C3\nGrids:\n    - C3-H8\n    - A2\n"))
+			})
+		})
+
+		Context("Contains", func() {
+			var si *api.GetSessionResponse
+
+			BeforeEach(func() {
+				si = &api.GetSessionResponse{
+					MapName:  "CARENTAN",
+					GameMode: "Skirmish",
+				}
+			})
+
+			triangle := data.Fence{Polygon: []data.Point{
+				{X: 0, Y: 0},
+				{X: 10000, Y: 0},
+				{X: 0, Y: 10000},
+			}}
+
+			It("includes position inside polygon", func() {
+				Expect(triangle.Contains(si, api.WorldPosition{X: 2000, Y: 2000})).To(BeTrue())
+			})
+
+			It("does not include position outside polygon", func() {
+				Expect(triangle.Contains(si, api.WorldPosition{X: 8000, Y: 8000})).To(BeFalse())
+			})
+
+			It("ignores the grid for polygon fences", func() {
+				f := triangle
+				f.X = Pointer("E")
+				Expect(f.Contains(si, api.WorldPosition{X: 8000, Y: 8000})).To(BeFalse())
+			})
+
+			It("does not include anything with less than three vertices", func() {
+				Expect(data.Fence{Polygon: []data.Point{{X: 0, Y: 0}, {X: 10000, Y: 0}}}.Contains(si, api.WorldPosition{X: 5000, Y: 0})).To(BeFalse())
+			})
+
+			It("uses the grid of Warfare in an Offensive", func() {
+				offensive := &api.GetSessionResponse{MapName: "CARENTAN", GameMode: "Offensive"}
+				p := api.WorldPosition{X: -10080, Y: -10080}
+				Expect(data.GridOf(offensive, p)).To(Equal(api.Grid{X: "E", Y: 5, Numpad: 5}))
+				Expect(data.Fence{X: Pointer("E"), Y: Pointer(5)}.Contains(offensive, p)).To(BeTrue())
+				c, ok := data.Radius{Grid: Pointer("E5"), Meters: 10}.Center(offensive)
+				Expect(ok).To(BeTrue())
+				Expect(c.X).To(BeNumerically("~", -10080))
+			})
+
+			It("falls back to the grid for grid fences", func() {
+				p := api.WorldPosition{X: 8000, Y: 8000}
+				Expect(data.Fence{X: Pointer(p.Grid(si).X)}.Contains(si, p)).To(BeTrue())
+			})
+
+			It("includes position within radius of world centre", func() {
+				Expect(data.Fence{Radius: &data.Radius{X: Pointer(1000.0), Y: Pointer(1000.0), Meters: 150}}.Contains(si, api.WorldPosition{X: 11000, Y: 1000, Z: 5000})).To(BeTrue())
+			})
+
+			It("does not include position outside radius of world centre", func() {
+				Expect(data.Fence{Radius: &data.Radius{X: Pointer(1000.0), Y: Pointer(1000.0), Meters: 150}}.Contains(si, api.WorldPosition{X: 16001, Y: 1000})).To(BeFalse())
+			})
+
+			DescribeTable("radius anchored on a grid", func(grid string, numpad *int) {
+				f := data.Fence{Radius: &data.Radius{Grid: Pointer(grid), Numpad: numpad, Meters: 10}}
+				c, ok := f.Radius.Center(si)
+				Expect(ok).To(BeTrue())
+				Expect(f.Contains(si, c)).To(BeTrue())
+
+				expected := 5
+				if numpad != nil {
+					expected = *numpad
+				}
+				Expect(c.Grid(si)).To(Equal(api.Grid{X: grid[:1], Y: int(grid[1] - '0'), Numpad: expected}))
+				Expect(f.Contains(si, api.WorldPosition{X: c.X + 1001, Y: c.Y})).To(BeFalse())
+			},
+				Entry("grid centre", "E5", nil),
+				Entry("numpad 7", "A1", Pointer(7)),
+				Entry("numpad 3", "H6", Pointer(3)),
+				Entry("numpad 4", "C8", Pointer(4)),
+			)
+
+			It("does not include anything when the grid reference is invalid", func() {
+				Expect(data.Fence{Radius: &data.Radius{Grid: Pointer("K4"), Meters: 100000}}.Contains(si, api.WorldPosition{X: 1, Y: 1})).To(BeFalse())
+			})
+		})
+
+		Context("Violates", func() {
+			var si *api.GetSessionResponse
+
+			BeforeEach(func() {
+				si = &api.GetSessionResponse{
+					MapName:  "CARENTAN",
+					GameMode: "Warfare",
+				}
+			})
+
+			// E5 numpad 5 and F5 numpad 5 on Carentan Warfare
+			e5 := api.WorldPosition{X: -10080, Y: -10080}
+			f5 := api.WorldPosition{X: 10080, Y: -10080}
+
+			It("does not violate without fences", func() {
+				Expect(data.Violates(nil, si, e5)).To(BeFalse())
+			})
+
+			It("does not violate when inside an allowed fence", func() {
+				Expect(data.Violates([]data.Fence{{X: Pointer("F")}, {X: Pointer("E")}}, si, e5)).To(BeFalse())
+			})
+
+			It("violates when inside no allowed fence", func() {
+				Expect(data.Violates([]data.Fence{{X: Pointer("F")}}, si, e5)).To(BeTrue())
+			})
+
+			It("violates when inside a deny fence within an allowed fence", func() {
+				Expect(data.Violates([]data.Fence{{Y: Pointer(5)}, {X: Pointer("E"), Deny: true}}, si, e5)).To(BeTrue())
+			})
+
+			It("does not violate when outside of deny fences only", func() {
+				Expect(data.Violates([]data.Fence{{X: Pointer("E"), Deny: true}}, si, f5)).To(BeFalse())
+			})
+
+			It("violates when inside of deny fences only", func() {
+				Expect(data.Violates([]data.Fence{{X: Pointer("E"), Y: Pointer(5), Numpads: []int{5}, Deny: true}}, si, e5)).To(BeTrue())
+			})
+
+			It("returns the fence deciding about the violation", func() {
+				fences := []data.Fence{{X: Pointer("F")}, {Y: Pointer(5)}, {X: Pointer("E"), Y: Pointer(5), Deny: true}}
+
+				violates, fence := data.Evaluate(fences, si, f5)
+				Expect(violates).To(BeFalse())
+				Expect(fence).To(BeIdenticalTo(&fences[0]))
+
+				violates, fence = data.Evaluate(fences, si, e5)
+				Expect(violates).To(BeTrue())
+				Expect(fence).To(BeIdenticalTo(&fences[2]))
+
+				violates, fence = data.Evaluate(fences[:1], si, e5)
+				Expect(violates).To(BeTrue())
+				Expect(fence).To(BeNil())
+			})
 		})
 
 		Context("Matches", func() {
@@ -84,7 +496,7 @@ var _ = Describe("Config", func() {
 			})
 
 			It("matches when no condition", func() {
-				Expect(data.Fence{}.Matches(si)).To(BeTrue())
+				Expect(data.Fence{}.Matches(data.GameState{Session: si})).To(BeTrue())
 			})
 
 			It("map with same name and mode", func() {
@@ -93,7 +505,7 @@ var _ = Describe("Config", func() {
 						"map_name":  {si.MapName},
 						"game_mode": {si.GameMode},
 					},
-				}}.Matches(si)).To(BeTrue())
+				}}.Matches(data.GameState{Session: si})).To(BeTrue())
 			})
 
 			It("does not match with wrong game mode", func() {
@@ -102,7 +514,7 @@ var _ = Describe("Config", func() {
 						"map_name":  {si.MapName},
 						"game_mode": {"Skirmish"},
 					},
-				}}.Matches(si)).To(BeFalse())
+				}}.Matches(data.GameState{Session: si})).To(BeFalse())
 			})
 
 			It("does not match with wrong map name", func() {
@@ -111,7 +523,7 @@ var _ = Describe("Config", func() {
 						"map_name":  {"TOBRUK"},
 						"game_mode": {si.GameMode},
 					},
-				}}.Matches(si)).To(BeFalse())
+				}}.Matches(data.GameState{Session: si})).To(BeFalse())
 			})
 
 			DescribeTable("when less than players than", func(pc int, expected bool) {
@@ -119,19 +531,81 @@ var _ = Describe("Config", func() {
 					LessThan: map[string]int{
 						"player_count": pc,
 					},
-				}}.Matches(si)).To(Equal(expected))
+				}}.Matches(data.GameState{Session: si})).To(Equal(expected))
 			},
 				Entry("more players", 20, false),
 				Entry("less players", 60, true),
 				Entry("equal number of players", 40, false),
 			)
 
+			DescribeTable("keys of the game state", func(c data.Condition, expected bool) {
+				s := data.GameState{
+					Session: &api.GetSessionResponse{
+						ServerName:     "Seeding server",
+						MapName:        "CARENTAN",
+						GameMode:       "Warfare",
+						PlayerCount:    40,
+						MaxPlayerCount: 100,
+						QueueCount:     3,
+						VIPQueueCount:  1,
+					},
+					AxisPlayers:   25,
+					AlliesPlayers: 15,
+				}
+				Expect(data.Fence{Condition: &c}.Matches(s)).To(Equal(expected))
+			},
+				Entry("server name", data.Condition{Equals: map[string][]string{"server_name": {"Seeding server"}}}, true),
+				Entry("other map", data.Condition{NotEquals: map[string][]string{"map_name": {"FOY", "KURSK"}}}, true),
+				Entry("not the map", data.Condition{NotEquals: map[string][]string{"map_name": {"CARENTAN"}}}, false),
+				Entry("max player count", data.Condition{LessThan: map[string]int{"max_player_count": 100}}, false),
+				Entry("queue", data.Condition{GreaterThan: map[string]int{"queue_count": 2}}, true),
+				Entry("vip queue", data.Condition{GreaterThan: map[string]int{"vip_queue_count": 1}}, false),
+				Entry("axis players", data.Condition{GreaterThan: map[string]int{"axis_player_count": 20}}, true),
+				Entry("allies players", data.Condition{LessThan: map[string]int{"allies_player_count": 15}}, false),
+				Entry("team imbalance", data.Condition{GreaterThan: map[string]int{"team_imbalance": 9}}, true),
+				Entry("within bounds", data.Condition{Between: map[string][]int{"player_count": {40, 60}}}, true),
+				Entry("outside of bounds", data.Condition{Between: map[string][]int{"player_count": {10, 39}}}, false),
+			)
+
+			DescribeTable("within a band", func(c data.Condition, band int, expected bool) {
+				s := data.GameState{Session: &api.GetSessionResponse{PlayerCount: 52}}
+				Expect(data.Fence{Condition: &c}.MatchesWithin(s, band)).To(Equal(expected))
+			},
+				Entry("less than without band", data.Condition{LessThan: map[string]int{"player_count": 50}}, 0, false),
+				Entry("less than within band", data.Condition{LessThan: map[string]int{"player_count": 50}}, 5, true),
+				Entry("less than beyond band", data.Condition{LessThan: map[string]int{"player_count": 50}}, 2, false),
+				Entry("greater than within band", data.Condition{GreaterThan: map[string]int{"player_count": 55}}, 5, true),
+				Entry("between within band", data.Condition{Between: map[string][]int{"player_count": {20, 50}}}, 5, true),
+				Entry("between beyond band", data.Condition{Between: map[string][]int{"player_count": {60, 70}}}, 5, false),
+				Entry("equals without band", data.Condition{Equals: map[string][]string{"map_name": {"FOY"}}}, 5, false),
+			)
+
+			It("does not widen invalid bounds", func() {
+				c := data.Condition{Between: map[string][]int{"player_count": {60, 50}}}
+				ok, err := data.Fence{Condition: &c}.MatchesWithin(data.GameState{Session: si}, 10)
+				Expect(ok).To(BeFalse())
+				Expect(err).To(MatchError("invalid bounds [60 50] of player_count for Between, expected a lower and an upper bound"))
+			})
+
+			DescribeTable("fails on invalid conditions", func(c data.Condition, message string) {
+				ok, err := data.Fence{Condition: &c}.Matches(data.GameState{Session: si})
+				Expect(ok).To(BeFalse())
+				Expect(err).To(MatchError(message))
+			},
+				Entry("unknown key", data.Condition{LessThan: map[string]int{"players": 10}},
+					"unknown condition key players for LessThan, expected one of allies_player_count, axis_player_count, max_player_count, player_count, queue_count, team_imbalance, vip_queue_count"),
+				Entry("key of another operator", data.Condition{Equals: map[string][]string{"player_count": {"10"}}},
+					"unknown condition key player_count for Equals, expected one of game_mode, map_name, server_name"),
+				Entry("missing bound", data.Condition{Between: map[string][]int{"player_count": {10}}},
+					"invalid bounds [10] of player_count for Between, expected a lower and an upper bound"),
+			)
+
 			DescribeTable("when greater than players than", func(pc int, expected bool) {
 				Expect(data.Fence{Condition: &data.Condition{
 					GreaterThan: map[string]int{
 						"player_count": pc,
 					},
-				}}.Matches(si)).To(Equal(expected))
+				}}.Matches(data.GameState{Session: si})).To(Equal(expected))
 			},
 				Entry("more players", 20, true),
 				Entry("less players", 60, false),
@@ -139,6 +613,147 @@ var _ = Describe("Config", func() {
 			)
 		})
 	})
+
+	Describe("Server", func() {
+		It("warns once when there is no warning schedule", func() {
+			s := data.Server{Messages: &data.Messages{Warning: Pointer("warning %s")}}
+			Expect(s.WarningSchedule()).To(Equal([]data.Warning{{AfterSeconds: 0, Message: Pointer("warning %s")}}))
+		})
+
+		It("sorts the warning schedule and uses the warning message as default", func() {
+			s := data.Server{
+				Messages: &data.Messages{Warning: Pointer("warning %s")},
+				Warnings: []data.Warning{{AfterSeconds: 8, Message: Pointer("last warning %s")}, {AfterSeconds: 0}, {AfterSeconds: 5}},
+			}
+			Expect(s.WarningSchedule()).To(Equal([]data.Warning{
+				{AfterSeconds: 0, Message: Pointer("warning %s")},
+				{AfterSeconds: 5, Message: Pointer("warning %s")},
+				{AfterSeconds: 8, Message: Pointer("last warning %s")},
+			}))
+			Expect(s.Warnings[1].Message).To(BeNil())
+		})
+
+		Context("For", func() {
+			s := data.Server{
+				PunishAfterSeconds: Pointer(10),
+				Messages:           &data.Messages{Warning: Pointer("server warning"), Punish: Pointer("server punish")},
+				AxisSettings:       &data.Settings{PunishAfterSeconds: Pointer(15), Messages: &data.Messages{Warning: Pointer("axis warning")}},
+			}
+			hq := &data.Fence{X: Pointer("A"), Settings: data.Settings{PunishAfterSeconds: Pointer(3), Messages: &data.Messages{Punish: Pointer("hq punish")}}}
+
+			It("uses the settings of the server without overrides", func() {
+				Expect(s.For(data.SideAllies, nil).PunishAfter()).To(Equal(10 * time.Second))
+				Expect(s.For(data.SideAllies, nil).WarningMessage()).To(Equal("server warning"))
+			})
+
+			It("uses the settings of the side", func() {
+				Expect(s.For(data.SideAxis, nil).PunishAfter()).To(Equal(15 * time.Second))
+				Expect(s.For(data.SideAxis, nil).WarningMessage()).To(Equal("axis warning"))
+				Expect(s.For(data.SideAxis, nil).PunishMessage()).To(Equal("server punish"))
+			})
+
+			It("prefers the settings of the fence", func() {
+				Expect(s.For(data.SideAxis, hq).PunishAfter()).To(Equal(3 * time.Second))
+				Expect(s.For(data.SideAxis, hq).WarningMessage()).To(Equal("axis warning"))
+				Expect(s.For(data.SideAxis, hq).PunishMessage()).To(Equal("hq punish"))
+				Expect(s.For(data.SideAllies, hq).WarningMessage()).To(Equal("server warning"))
+			})
+
+			It("prefers the warning message of the side over the messages of the warning schedule", func() {
+				s := s
+				s.Warnings = []data.Warning{{AfterSeconds: 0, Message: Pointer("first warning")}, {AfterSeconds: 5}}
+				Expect(s.For(data.SideAxis, hq).WarningSchedule()).To(Equal([]data.Warning{
+					{AfterSeconds: 0, Message: Pointer("axis warning")},
+					{AfterSeconds: 5, Message: Pointer("axis warning")},
+				}))
+				Expect(s.For(data.SideAllies, hq).WarningSchedule()).To(Equal([]data.Warning{
+					{AfterSeconds: 0, Message: Pointer("first warning")},
+					{AfterSeconds: 5, Message: Pointer("server warning")},
+				}))
+			})
+
+			It("prefers overridden messages over the environment", func() {
+				Expect(os.Setenv("PUNISH_MESSAGE", "env punish")).To(Succeed())
+				defer os.Unsetenv("PUNISH_MESSAGE")
+				Expect(s.For(data.SideAxis, nil).PunishMessage()).To(Equal("env punish"))
+				Expect(s.For(data.SideAxis, hq).PunishMessage()).To(Equal("hq punish"))
+			})
+		})
+
+		Context("Fences", func() {
+			axis, allies, dak := []data.Fence{{X: Pointer("A")}}, []data.Fence{{X: Pointer("J")}}, []data.Fence{{X: Pointer("B")}}
+
+			It("resolves the factions to their side", func() {
+				s := data.Server{AxisFence: axis, AlliesFence: allies}
+				for _, f := range []data.Faction{data.FactionGER, data.FactionDAK} {
+					fences, side, ok := s.Fences(f, nil)
+					Expect(ok).To(BeTrue())
+					Expect(side).To(Equal(data.SideAxis))
+					Expect(fences).To(Equal(axis))
+				}
+				for _, f := range []data.Faction{data.FactionUS, data.FactionRUS, data.FactionGB, data.FactionB8A} {
+					fences, side, ok := s.Fences(f, nil)
+					Expect(ok).To(BeTrue())
+					Expect(side).To(Equal(data.SideAllies))
+					Expect(fences).To(Equal(allies))
+				}
+			})
+
+			It("prefers the fences of the faction", func() {
+				fences, side, _ := data.Server{AxisFence: axis, DAKFence: dak}.Fences(data.FactionDAK, nil)
+				Expect(side).To(Equal(data.SideAxis))
+				Expect(fences).To(Equal(dak))
+			})
+
+			It("uses the side of the faction from the config", func() {
+				fences, side, _ := data.Server{AxisFence: axis, AlliesFence: allies, Factions: map[data.Faction]data.Side{data.FactionDAK: data.SideAllies}}.Fences(data.FactionDAK, nil)
+				Expect(side).To(Equal(data.SideAllies))
+				Expect(fences).To(Equal(allies))
+			})
+
+			It("does not resolve unknown factions", func() {
+				_, _, ok := data.Server{}.Fences("ITA", nil)
+				Expect(ok).To(BeFalse())
+			})
+
+			Context("in an Offensive", func() {
+				attacker, defender := []data.Fence{{X: Pointer("C")}}, []data.Fence{{X: Pointer("D")}}
+				s := data.Server{AxisFence: axis, AlliesFence: allies, AttackerFence: attacker, DefenderFence: defender, DAKFence: dak}
+				offensive := &api.GetSessionResponse{MapName: "CARENTAN", GameMode: "Offensive"}
+
+				It("uses the fences of the attackers and defenders", func() {
+					fences, side, _ := s.Fences(data.FactionUS, offensive)
+					Expect(side).To(Equal(data.SideAllies))
+					Expect(fences).To(Equal(attacker))
+					fences, side, _ = s.Fences(data.FactionGER, offensive)
+					Expect(side).To(Equal(data.SideAxis))
+					Expect(fences).To(Equal(defender))
+				})
+
+				It("uses the attacking side of the map from the config", func() {
+					s := s
+					s.OffensiveAttackers = map[string]data.Side{"CARENTAN": data.SideAxis}
+					fences, _, _ := s.Fences(data.FactionGER, offensive)
+					Expect(fences).To(Equal(attacker))
+				})
+
+				It("prefers the fences of the faction", func() {
+					fences, _, _ := s.Fences(data.FactionDAK, offensive)
+					Expect(fences).To(Equal(dak))
+				})
+
+				It("ignores the fences of the attackers and defenders in other game modes", func() {
+					fences, _, _ := s.Fences(data.FactionUS, &api.GetSessionResponse{MapName: "CARENTAN", GameMode: "Warfare"})
+					Expect(fences).To(Equal(allies))
+				})
+			})
+		})
+
+		It("does not send a back inside message by default", func() {
+			Expect(data.Server{}.BackInsideMessage()).To(BeEmpty())
+			Expect(data.Server{Messages: &data.Messages{BackInside: Pointer("welcome back")}}.BackInsideMessage()).To(Equal("welcome back"))
+		})
+	})
 })
 
 func Pointer[T any](v T) *T {
@@ -0,0 +1,75 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+type Action string
+
+const (
+	ActionPunish  Action = "punish"
+	ActionKick    Action = "kick"
+	ActionTempBan Action = "temp-ban"
+)
+
+var actions = []string{string(ActionPunish), string(ActionKick), string(ActionTempBan)}
+
+// Escalation is a ladder of actions taken against players who leave the play area repeatedly. Each violation of a
+// player within the rolling window counts, regardless of map changes in between.
+type Escalation struct {
+	WindowMinutes int              `yaml:"WindowMinutes"`
+	Steps         []EscalationStep `yaml:"Steps"`
+}
+
+type EscalationStep struct {
+	// Violations is the number of violations within the window from which on this step applies.
+	Violations int    `yaml:"Violations"`
+	Action     Action `yaml:"Action"`
+	// Hours is the duration of the ban of ActionTempBan.
+	Hours  int     `yaml:"Hours,omitempty"`
+	Reason *string `yaml:"Reason,omitempty"`
+}
+
+// ReasonOr returns the configured reason of the step, or def if there is none.
+func (s EscalationStep) ReasonOr(def string) string {
+	if s.Reason == nil {
+		return def
+	}
+	return *s.Reason
+}
+
+func (s EscalationStep) String() string {
+	if s.Action == ActionTempBan {
+		return fmt.Sprintf("%s (%dh)", s.Action, s.Hours)
+	}
+	return string(s.Action)
+}
+
+// Window is the rolling window violations are counted in. The default is one hour.
+func (e *Escalation) Window() time.Duration {
+	if e == nil || e.WindowMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(e.WindowMinutes) * time.Minute
+}
+
+// Step returns the step with the highest number of Violations which is reached by violations. Players are punished
+// when no step applies, e.g., when there is no Escalation at all.
+func (e *Escalation) Step(violations int) EscalationStep {
+	step := EscalationStep{Violations: 1, Action: ActionPunish}
+	if e == nil {
+		return step
+	}
+	steps := slices.Clone(e.Steps)
+	slices.SortStableFunc(steps, func(a, b EscalationStep) int {
+		return a.Violations - b.Violations
+	})
+	for _, s := range steps {
+		if s.Violations <= violations {
+			step = s
+		}
+	}
+	return step
+}
@@ -0,0 +1,38 @@
+package data_test
+
+import (
+	"time"
+
+	"github.com/floriansw/hll-geofences/data"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Escalation", func() {
+	e := &data.Escalation{
+		WindowMinutes: 30,
+		Steps: []data.EscalationStep{
+			{Violations: 5, Action: data.ActionTempBan, Hours: 2},
+			{Violations: 1, Action: data.ActionPunish},
+			{Violations: 3, Action: data.ActionKick},
+		},
+	}
+
+	DescribeTable("Step", func(e *data.Escalation, violations int, expected data.Action) {
+		Expect(e.Step(violations).Action).To(Equal(expected))
+	},
+		Entry("punishes without escalation", nil, 7, data.ActionPunish),
+		Entry("first violation", e, 1, data.ActionPunish),
+		Entry("second violation", e, 2, data.ActionPunish),
+		Entry("third violation", e, 3, data.ActionKick),
+		Entry("fifth violation", e, 5, data.ActionTempBan),
+		Entry("after the last step", e, 9, data.ActionTempBan),
+		Entry("before the first step", &data.Escalation{Steps: []data.EscalationStep{{Violations: 2, Action: data.ActionKick}}}, 1, data.ActionPunish),
+	)
+
+	It("uses one hour as the default window", func() {
+		Expect((*data.Escalation)(nil).Window()).To(Equal(time.Hour))
+		Expect(e.Window()).To(Equal(30 * time.Minute))
+	})
+})
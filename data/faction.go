@@ -0,0 +1,85 @@
+package data
+
+import (
+	"github.com/floriansw/go-hll-rcon/rconv2/api"
+)
+
+// Faction is the army a team plays in a match, e.g., the Afrika Korps on El Alamein.
+type Faction string
+
+const (
+	FactionGER Faction = "GER"
+	FactionUS  Faction = "US"
+	FactionRUS Faction = "RUS"
+	FactionGB  Faction = "GB"
+	FactionDAK Faction = "DAK"
+	FactionB8A Faction = "B8A"
+)
+
+// Factions are all factions of the game.
+var Factions = []Faction{FactionGER, FactionUS, FactionRUS, FactionGB, FactionDAK, FactionB8A}
+
+// defaultSides are the sides the factions play on.
+var defaultSides = map[Faction]Side{
+	FactionGER: SideAxis,
+	FactionDAK: SideAxis,
+	FactionUS:  SideAllies,
+	FactionRUS: SideAllies,
+	FactionGB:  SideAllies,
+	FactionB8A: SideAllies,
+}
+
+// Side returns the side the players of faction f are on, which is the one of the Factions of the server, if set.
+// It reports false for unknown factions.
+func (s Server) Side(f Faction) (Side, bool) {
+	if side, ok := s.Factions[f]; ok {
+		return side, true
+	}
+	side, ok := defaultSides[f]
+	return side, ok
+}
+
+// FactionFences returns the fences of the factions, which have fences of their own, by faction.
+func (s Server) FactionFences() map[Faction][]Fence {
+	v := map[Faction][]Fence{}
+	for f, fences := range map[Faction][]Fence{
+		FactionGER: s.GERFence,
+		FactionUS:  s.USFence,
+		FactionRUS: s.RUSFence,
+		FactionGB:  s.GBFence,
+		FactionDAK: s.DAKFence,
+		FactionB8A: s.B8AFence,
+	} {
+		if len(fences) != 0 {
+			v[f] = fences
+		}
+	}
+	return v
+}
+
+// Fences returns the fences, which apply to the players of faction f on the map of the given session, together with
+// their side. These are the fences of the faction, if it has fences of its own, then the AttackerFence or
+// DefenderFence while an Offensive is played, and otherwise the ones of its side. It reports false for unknown
+// factions.
+func (s Server) Fences(f Faction, si *api.GetSessionResponse) ([]Fence, Side, bool) {
+	side, ok := s.Side(f)
+	if !ok {
+		return nil, "", false
+	}
+	if fences := s.FactionFences()[f]; len(fences) != 0 {
+		return fences, side, true
+	}
+	if attacker, ok := s.Attacker(si); ok {
+		fences := s.DefenderFence
+		if side == attacker {
+			fences = s.AttackerFence
+		}
+		if len(fences) != 0 {
+			return fences, side, true
+		}
+	}
+	if side == SideAxis {
+		return s.AxisFence, side, true
+	}
+	return s.AlliesFence, side, true
+}
@@ -0,0 +1,191 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+type Layout string
+
+const (
+	// LayoutMidcap allows the own half of the map up to the line of the midpoint on the enemy's half, as well as the
+	// line of the enemy's HQs, which blocks the remaining three lines of the enemy. This is the layout of the shipped
+	// seeding.midcap configs.
+	LayoutMidcap Layout = "midcap"
+	// LayoutStrip allows a strip of GenerateOptions.Width lines in the middle of the map.
+	LayoutStrip Layout = "strip"
+	// LayoutLastLinesBlocked allows everything except the last GenerateOptions.Lines lines of the enemy.
+	LayoutLastLinesBlocked Layout = "last-lines-blocked"
+)
+
+// mapLayout describes the direction in which a map is played. Lines are the columns (A-J) of horizontal maps and the
+// rows (1-10) of vertical maps.
+type mapLayout struct {
+	Vertical bool
+	// AlliesFirst is true when the Allies HQs are in the first line (column A or row 1) of the map.
+	AlliesFirst bool
+}
+
+func (m mapLayout) name() string {
+	side, direction := "axis", "west"
+	if m.AlliesFirst {
+		side = "allies"
+	}
+	if m.Vertical {
+		direction = "north"
+	}
+	return side + "-" + direction
+}
+
+// mapLayouts are the warfare layouts of all maps the generator knows about.
+var mapLayouts = map[string]mapLayout{
+	"CARENTAN":           {AlliesFirst: true},
+	"HILL 400":           {AlliesFirst: true},
+	"HÜRTGEN FOREST":     {AlliesFirst: true},
+	"MORTAIN":            {AlliesFirst: true},
+	"EL ALAMEIN":         {},
+	"OMAHA BEACH":        {},
+	"SAINTE-MÈRE-ÉGLISE": {},
+	"STALINGRAD":         {},
+	"TOBRUK":             {},
+	"UTAH BEACH":         {},
+	"ELSENBORN RIDGE":    {Vertical: true, AlliesFirst: true},
+	"KHARKOV":            {Vertical: true, AlliesFirst: true},
+	"KURSK":              {Vertical: true, AlliesFirst: true},
+	"PURPLE HEART LANE":  {Vertical: true, AlliesFirst: true},
+	"ST MARIE DU MONT":   {Vertical: true, AlliesFirst: true},
+	"DRIEL":              {Vertical: true},
+	"FOY":                {Vertical: true},
+	"REMAGEN":            {Vertical: true},
+}
+
+// KnownMaps returns the names of all maps the generator knows the layout of, sorted by name.
+func KnownMaps() []string {
+	var v []string
+	for name := range mapLayouts {
+		v = append(v, name)
+	}
+	slices.Sort(v)
+	return v
+}
+
+type GenerateOptions struct {
+	Layout Layout
+	// Maps the fences are generated for. All KnownMaps are used when empty.
+	Maps     []string
+	GameMode string
+	// PlayerCount is the number of players from which on the fences do not apply anymore.
+	PlayerCount int
+	// Width is the number of lines of the strip for LayoutStrip.
+	Width int
+	// Lines is the number of the enemy's lines which are blocked for LayoutLastLinesBlocked.
+	Lines int
+	// Server is used as a template for the generated server, its fences are replaced.
+	Server Server
+}
+
+// Generate creates a config with a single server for a common seeding layout. Each side of a map is allowed to use
+// the lines of its own HQs and the outermost lines along the edges of the map in addition to what the layout allows.
+func Generate(o GenerateOptions) (*Config, error) {
+	switch o.Layout {
+	case LayoutMidcap:
+	case LayoutStrip:
+		if o.Width < 2 || o.Width > 8 || o.Width%2 != 0 {
+			return nil, fmt.Errorf("width must be an even number between 2 and 8, got %d", o.Width)
+		}
+	case LayoutLastLinesBlocked:
+		if o.Lines < 1 || o.Lines > 8 {
+			return nil, fmt.Errorf("lines must be between 1 and 8, got %d", o.Lines)
+		}
+	default:
+		return nil, fmt.Errorf("unknown layout %q", o.Layout)
+	}
+	if o.GameMode == "" {
+		return nil, errors.New("game mode cannot be empty")
+	}
+	if o.PlayerCount <= 0 {
+		return nil, fmt.Errorf("player count must be greater than 0, got %d", o.PlayerCount)
+	}
+	if len(o.Maps) == 0 {
+		o.Maps = KnownMaps()
+	}
+
+	c := &Config{Conditions: map[string]Condition{}}
+	var layouts []mapLayout
+	for _, name := range o.Maps {
+		l, ok := mapLayouts[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown map %s, known maps are: %v", name, KnownMaps())
+		}
+		if !slices.Contains(layouts, l) {
+			layouts = append(layouts, l)
+		}
+		condition := c.Conditions[l.name()]
+		if condition.Equals == nil {
+			condition = Condition{
+				Equals:   map[string][]string{"game_mode": {o.GameMode}},
+				LessThan: map[string]int{"player_count": o.PlayerCount},
+			}
+		}
+		condition.Equals["map_name"] = append(condition.Equals["map_name"], name)
+		c.Conditions[l.name()] = condition
+	}
+
+	s := o.Server
+	s.AxisFence, s.AlliesFence = nil, nil
+	for _, l := range layouts {
+		s.AlliesFence = append(s.AlliesFence, o.fence(l, l.AlliesFirst))
+		s.AxisFence = append(s.AxisFence, o.fence(l, !l.AlliesFirst))
+	}
+	c.Servers = []Server{s}
+	return c, nil
+}
+
+// fence returns the fence of the side of a map, which has its HQs in the first line when first is true.
+func (o GenerateOptions) fence(l mapLayout, first bool) Fence {
+	hq := 10
+	if first {
+		hq = 1
+	}
+	grids := l.edges()
+	switch o.Layout {
+	case LayoutMidcap:
+		if first {
+			grids = append(grids, l.lines(1, 6), l.lines(10, 10))
+		} else {
+			grids = append(grids, l.lines(1, 1), l.lines(5, 10))
+		}
+	case LayoutStrip:
+		grids = append(grids, l.lines(hq, hq), l.lines(6-o.Width/2, 5+o.Width/2))
+	case LayoutLastLinesBlocked:
+		if first {
+			grids = append(grids, l.lines(1, 10-o.Lines))
+		} else {
+			grids = append(grids, l.lines(1+o.Lines, 10))
+		}
+	}
+	return Fence{Grids: grids, Condition: &Condition{ref: l.name()}}
+}
+
+// edges returns the outermost lines along the sides of the map, which are allowed for both sides.
+func (m mapLayout) edges() []GridRange {
+	if m.Vertical {
+		return []GridRange{
+			{From: GridRef{X: "B", Y: 1}, To: GridRef{X: "B", Y: 10}},
+			{From: GridRef{X: "I", Y: 1}, To: GridRef{X: "I", Y: 10}},
+		}
+	}
+	return []GridRange{
+		{From: GridRef{X: "A", Y: 2}, To: GridRef{X: "J", Y: 2}},
+		{From: GridRef{X: "A", Y: 9}, To: GridRef{X: "J", Y: 9}},
+	}
+}
+
+// lines returns the grids of the lines from to to, without the edges of the map.
+func (m mapLayout) lines(from, to int) GridRange {
+	if m.Vertical {
+		return GridRange{From: GridRef{X: "C", Y: from}, To: GridRef{X: "H", Y: to}}
+	}
+	return GridRange{From: GridRef{X: gridColumns[from-1], Y: 3}, To: GridRef{X: gridColumns[to-1], Y: 8}}
+}
@@ -0,0 +1,149 @@
+package data_test
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+
+	"github.com/floriansw/go-hll-rcon/rconv2/api"
+	"github.com/floriansw/hll-geofences/data"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Generate", func() {
+	includes := func(fences []data.Fence, si *api.GetSessionResponse, grid string) bool {
+		g := api.Grid{X: grid[:1], Y: int(grid[1] - '0'), Numpad: 5}
+		if len(grid) == 3 {
+			g.Y = 10
+		}
+		for _, f := range fences {
+			if ok, _ := f.Matches(data.GameState{Session: si}); ok && f.Includes(g) {
+				return true
+			}
+		}
+		return false
+	}
+
+	generate := func(o data.GenerateOptions) *data.Server {
+		c, err := data.Generate(o)
+		Expect(err).ToNot(HaveOccurred())
+
+		// the generated config is read back to make sure it is valid and references are resolved
+		out, err := c.Marshal()
+		Expect(err).ToNot(HaveOccurred())
+		f, err := os.CreateTemp(os.TempDir(), "config")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.Remove(f.Name())
+		Expect(os.WriteFile(f.Name(), out, 0600)).To(Succeed())
+		c, err = data.NewConfig(f.Name(), slog.New(slog.NewTextHandler(os.Stdout, nil)))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(c.Servers).To(HaveLen(1))
+		return &c.Servers[0]
+	}
+
+	carentan := &api.GetSessionResponse{MapName: "CARENTAN", GameMode: "Warfare", PlayerCount: 20}
+	foy := &api.GetSessionResponse{MapName: "FOY", GameMode: "Warfare", PlayerCount: 20}
+
+	DescribeTable("midcap layout", func(si *api.GetSessionResponse, axis bool, grid string, expected bool) {
+		s := generate(data.GenerateOptions{Layout: data.LayoutMidcap, Maps: []string{"CARENTAN", "FOY"}, GameMode: "Warfare", PlayerCount: 40})
+		fences := s.AlliesFence
+		if axis {
+			fences = s.AxisFence
+		}
+		Expect(includes(fences, si, grid)).To(Equal(expected))
+	},
+		Entry("allies HQ line on horizontal map", carentan, false, "A5", true),
+		Entry("axis HQ line on horizontal map", carentan, true, "J5", true),
+		Entry("enemy HQ line on horizontal map", carentan, false, "J5", true),
+		Entry("midcap on horizontal map", carentan, false, "F8", true),
+		Entry("edge on horizontal map", carentan, true, "B2", true),
+		Entry("enemy lines on horizontal map", carentan, false, "G5", false),
+		Entry("enemy lines on horizontal map for axis", carentan, true, "D5", false),
+		Entry("axis HQ line on vertical map", foy, true, "C1", true),
+		Entry("allies HQ line on vertical map", foy, false, "H10", true),
+		Entry("midcap on vertical map", foy, true, "C6", true),
+		Entry("edge on vertical map", foy, false, "B3", true),
+		Entry("enemy lines on vertical map", foy, false, "D3", false),
+		Entry("outside of the map on vertical map", foy, false, "A7", false),
+	)
+
+	DescribeTable("last lines blocked layout", func(si *api.GetSessionResponse, axis bool, grid string, expected bool) {
+		s := generate(data.GenerateOptions{Layout: data.LayoutLastLinesBlocked, Maps: []string{"CARENTAN", "FOY"}, GameMode: "Warfare", PlayerCount: 60, Lines: 2})
+		fences := s.AlliesFence
+		if axis {
+			fences = s.AxisFence
+		}
+		Expect(includes(fences, si, grid)).To(Equal(expected))
+	},
+		Entry("own last line on horizontal map", carentan, false, "A5", true),
+		Entry("up to the enemy's last lines on horizontal map", carentan, false, "H5", true),
+		Entry("enemy's last lines on horizontal map", carentan, false, "I5", false),
+		Entry("enemy's last lines on horizontal map for axis", carentan, true, "B5", false),
+		Entry("up to the enemy's last lines on vertical map", foy, true, "E8", true),
+		Entry("enemy's last lines on vertical map", foy, true, "E9", false),
+		Entry("enemy's last lines on vertical map for allies", foy, false, "E2", false),
+	)
+
+	DescribeTable("shipped configs", func(file string, o data.GenerateOptions) {
+		c, err := data.NewConfig(file, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+		Expect(err).ToNot(HaveOccurred())
+		shipped := c.Servers[0]
+		s := generate(o)
+		// restricted reports whether any of the fences applies on the map of si, a side without fences is not restricted
+		restricted := func(fences []data.Fence, si *api.GetSessionResponse) bool {
+			return slices.ContainsFunc(fences, func(f data.Fence) bool {
+				ok, _ := f.Matches(data.GameState{Session: si})
+				return ok
+			})
+		}
+		for _, m := range data.KnownMaps() {
+			si := &api.GetSessionResponse{MapName: m, GameMode: "Warfare", PlayerCount: o.PlayerCount - 1}
+			for _, side := range [][2][]data.Fence{{s.AxisFence, shipped.AxisFence}, {s.AlliesFence, shipped.AlliesFence}} {
+				generated, shipped := side[0], side[1]
+				if !restricted(shipped, si) {
+					// the shipped 3caps configs do not restrict the Axis on the vertical maps with the Allies in the north
+					continue
+				}
+				for _, x := range "ABCDEFGHIJ" {
+					for y := 1; y <= 10; y++ {
+						grid := fmt.Sprintf("%c%d", x, y)
+						if grid == "I1" {
+							// the shipped midcap configs allow I1 on some horizontal maps, which is outside of the playable area
+							continue
+						}
+						Expect(includes(generated, si, grid)).To(Equal(includes(shipped, si, grid)), "%s on %s", grid, m)
+					}
+				}
+			}
+		}
+	},
+		Entry("midcap", "../seeding.midcap.40player.config.yml", data.GenerateOptions{Layout: data.LayoutMidcap, GameMode: "Warfare", PlayerCount: 40}),
+		Entry("last two lines blocked", "../seeding.3caps.60player.config.yml", data.GenerateOptions{Layout: data.LayoutLastLinesBlocked, Lines: 2, GameMode: "Warfare", PlayerCount: 60}),
+	)
+
+	It("does not apply fences with more players", func() {
+		s := generate(data.GenerateOptions{Layout: data.LayoutMidcap, Maps: []string{"CARENTAN"}, GameMode: "Warfare", PlayerCount: 40})
+		Expect(includes(s.AlliesFence, &api.GetSessionResponse{MapName: "CARENTAN", GameMode: "Warfare", PlayerCount: 40}, "A5")).To(BeFalse())
+	})
+
+	It("generates strips with custom width", func() {
+		s := generate(data.GenerateOptions{Layout: data.LayoutStrip, Maps: []string{"CARENTAN"}, GameMode: "Warfare", PlayerCount: 40, Width: 4})
+		Expect(includes(s.AlliesFence, carentan, "D5")).To(BeTrue())
+		Expect(includes(s.AlliesFence, carentan, "G5")).To(BeTrue())
+		Expect(includes(s.AlliesFence, carentan, "H5")).To(BeFalse())
+	})
+
+	DescribeTable("invalid options", func(o data.GenerateOptions) {
+		_, err := data.Generate(o)
+		Expect(err).To(HaveOccurred())
+	},
+		Entry("unknown layout", data.GenerateOptions{Layout: "unknown", GameMode: "Warfare", PlayerCount: 40}),
+		Entry("unknown map", data.GenerateOptions{Layout: data.LayoutMidcap, Maps: []string{"ATLANTIS"}, GameMode: "Warfare", PlayerCount: 40}),
+		Entry("odd strip width", data.GenerateOptions{Layout: data.LayoutStrip, Width: 3, GameMode: "Warfare", PlayerCount: 40}),
+		Entry("too many blocked lines", data.GenerateOptions{Layout: data.LayoutLastLinesBlocked, Lines: 9, GameMode: "Warfare", PlayerCount: 40}),
+		Entry("missing player count", data.GenerateOptions{Layout: data.LayoutMidcap, GameMode: "Warfare"}),
+	)
+})
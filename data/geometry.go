@@ -0,0 +1,121 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/floriansw/go-hll-rcon/rconv2/api"
+	"gopkg.in/yaml.v3"
+)
+
+var gridColumns = []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+
+// mapCenterOffsets mirrors the map centre offsets rconv2 uses to translate a world position into a grid. They are not
+// exported by rconv2, but are needed to translate a grid back into a world position. Maps not listed here have their
+// centre at 0,0.
+var mapCenterOffsets = map[string]map[string]api.Vector2D{
+	"Skirmish": {
+		"CARENTAN":         {X: 150, Y: -110},
+		"MORTAIN":          {X: 100, Y: 0},
+		"ST MARIE DU MONT": {X: 0, Y: -27852.799},
+		"DRIEL":            {X: -20, Y: 28190},
+	},
+}
+
+// parseGrid parses a grid reference like E5 or J10 into its column and row.
+func parseGrid(s string) (string, int, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 || !slices.Contains(gridColumns, s[:1]) {
+		return "", 0, fmt.Errorf("invalid grid %q, expected a column A-J followed by a row 1-10", s)
+	}
+	y, err := strconv.Atoi(s[1:])
+	if err != nil || y < 1 || y > 10 {
+		return "", 0, fmt.Errorf("invalid grid %q, expected a column A-J followed by a row 1-10", s)
+	}
+	return s[:1], y, nil
+}
+
+// GridRef is a reference to a whole grid, written like E5 or J10 in the config.
+type GridRef struct {
+	X string
+	Y int
+}
+
+func (g GridRef) String() string {
+	return fmt.Sprintf("%s%d", g.X, g.Y)
+}
+
+func (g *GridRef) UnmarshalYAML(value *yaml.Node) error {
+	x, y, err := parseGrid(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*g = GridRef{X: x, Y: y}
+	return nil
+}
+
+func (g GridRef) MarshalYAML() (interface{}, error) {
+	return g.String(), nil
+}
+
+// GridRange is a rectangle of grids between two corners, written like C3-H8 in the config. A single grid, like A2, is
+// a range where both corners are the same grid.
+type GridRange struct {
+	From GridRef
+	To   GridRef
+}
+
+func (r GridRange) String() string {
+	if r.From == r.To {
+		return r.From.String()
+	}
+	return r.From.String() + "-" + r.To.String()
+}
+
+// Includes reports whether w is within the rectangle. The order of the corners does not matter.
+func (r GridRange) Includes(w api.Grid) bool {
+	x := slices.Index(gridColumns, w.X)
+	fromX, toX := slices.Index(gridColumns, r.From.X), slices.Index(gridColumns, r.To.X)
+	return x >= min(fromX, toX) && x <= max(fromX, toX) && w.Y >= min(r.From.Y, r.To.Y) && w.Y <= max(r.From.Y, r.To.Y)
+}
+
+func (r *GridRange) UnmarshalYAML(value *yaml.Node) error {
+	from, to, found := strings.Cut(value.Value, "-")
+	if !found {
+		to = from
+	}
+	fx, fy, err := parseGrid(from)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	tx, ty, err := parseGrid(to)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*r = GridRange{From: GridRef{X: fx, Y: fy}, To: GridRef{X: tx, Y: ty}}
+	return nil
+}
+
+func (r GridRange) MarshalYAML() (interface{}, error) {
+	return r.String(), nil
+}
+
+// gridCenter returns the world position of the centre of the numpad in grid x, y on the map of the given session.
+// This is the inverse of api.WorldPosition.Grid.
+func gridCenter(si *api.GetSessionResponse, x string, y, numpad int) (api.WorldPosition, bool) {
+	size := geometry(si).GridSize()
+	col := slices.Index(gridColumns, x)
+	if size == 0 || col == -1 || numpad < 1 || numpad > 9 {
+		return api.WorldPosition{}, false
+	}
+	o := mapCenterOffsets[si.GameMode][si.MapName]
+	cell := size / 3
+	// numpads are laid out like on a keyboard, 7, 8 and 9 are in the first row of the grid
+	numpadCol, numpadRow := (numpad-1)%3, 2-(numpad-1)/3
+	return api.WorldPosition{
+		X: o.X + float64(col-5)*size + float64(numpadCol)*cell + cell/2,
+		Y: o.Y + float64(y-6)*size + float64(numpadRow)*cell + cell/2,
+	}, true
+}
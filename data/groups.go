@@ -0,0 +1,105 @@
+package data
+
+import (
+	"fmt"
+)
+
+// FenceGroup is a named set of fences sharing an optional Condition. Fences reference a group in Server.AxisFence,
+// Server.AlliesFence or the fences of a faction with Group.
+type FenceGroup struct {
+	Condition *Condition `yaml:"Condition,omitempty"`
+	Fences    []Fence    `yaml:"Fences"`
+}
+
+// Expand returns the fences of the group the fence references, or the fence itself, if it does not reference a group.
+// Each fence of the group only matches when the conditions of the group and of the referencing fence match as well,
+// and uses the Settings of the referencing fence unless it has its own.
+func (f Fence) Expand() []Fence {
+	if f.Group == nil {
+		return []Fence{f}
+	}
+	return f.members
+}
+
+// resolve replaces references to named conditions and fence groups with what they reference. It fails when a name is
+// unknown.
+func (c *Config) resolve() error {
+	for name, condition := range c.Conditions {
+		if condition.ref != "" {
+			return fmt.Errorf("condition %s: cannot reference another condition (%s)", name, condition.ref)
+		}
+	}
+	groups := map[string]FenceGroup{}
+	for name, g := range c.FenceGroups {
+		if err := c.resolveCondition(g.Condition); err != nil {
+			return fmt.Errorf("fence group %s: %w", name, err)
+		}
+		for i := range g.Fences {
+			if g.Fences[i].Group != nil {
+				return fmt.Errorf("fence group %s: cannot reference another fence group (%s)", name, *g.Fences[i].Group)
+			}
+			if err := c.resolveCondition(g.Fences[i].Condition); err != nil {
+				return fmt.Errorf("fence group %s: %w", name, err)
+			}
+		}
+		groups[name] = g
+	}
+	for _, s := range c.Servers {
+		all := map[string][]Fence{
+			"AxisFence":     s.AxisFence,
+			"AlliesFence":   s.AlliesFence,
+			"AttackerFence": s.AttackerFence,
+			"DefenderFence": s.DefenderFence,
+		}
+		for f, fences := range s.FactionFences() {
+			all[string(f)+"Fence"] = fences
+		}
+		for name, fences := range all {
+			if err := c.resolveFences(groups, fences); err != nil {
+				return fmt.Errorf("server %s:%d: %s: %w", s.Host, s.Port, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Config) resolveFences(groups map[string]FenceGroup, fences []Fence) error {
+	for i := range fences {
+		f := &fences[i]
+		if err := c.resolveCondition(f.Condition); err != nil {
+			return err
+		}
+		if f.Group == nil {
+			continue
+		}
+		g, ok := groups[*f.Group]
+		if !ok {
+			return fmt.Errorf("unknown fence group %s", *f.Group)
+		}
+		f.members = nil
+		for _, m := range g.Fences {
+			if g.Condition != nil {
+				m.inherited = append(m.inherited, g.Condition)
+			}
+			if f.Condition != nil {
+				m.inherited = append(m.inherited, f.Condition)
+			}
+			m.Settings = m.Settings.or(&f.Settings)
+			f.members = append(f.members, m)
+		}
+	}
+	return nil
+}
+
+func (c *Config) resolveCondition(condition *Condition) error {
+	if condition == nil || condition.ref == "" {
+		return nil
+	}
+	named, ok := c.Conditions[condition.ref]
+	if !ok {
+		return fmt.Errorf("unknown condition %s", condition.ref)
+	}
+	named.ref = condition.ref
+	*condition = named
+	return nil
+}
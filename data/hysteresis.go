@@ -0,0 +1,42 @@
+package data
+
+import (
+	"time"
+)
+
+// Hysteresis keeps the applicable fences of a server from flipping on and off, when the game state is close to the
+// thresholds of their conditions, e.g., while players join and leave around a player count of 50.
+type Hysteresis struct {
+	// Band is the distance to the thresholds of LessThan, GreaterThan and Between, by which a fence, which applies
+	// already, keeps applying. E.g., a fence with LessThan player_count 50 and a Band of 5 applies below 50 players,
+	// but stops applying at 55 players only.
+	Band int `yaml:"Band,omitempty"`
+	// HoldSeconds is the minimum time the applicable fences of a side are kept, before they change again.
+	HoldSeconds int `yaml:"HoldSeconds,omitempty"`
+	// Samples is the number of recent session polls, whose average player count is used for the conditions.
+	Samples int `yaml:"Samples,omitempty"`
+}
+
+// Width returns the band of the hysteresis, which is 0 when h is nil.
+func (h *Hysteresis) Width() int {
+	if h == nil {
+		return 0
+	}
+	return h.Band
+}
+
+// Hold returns the minimum time the applicable fences are kept, which is 0 when h is nil.
+func (h *Hysteresis) Hold() time.Duration {
+	if h == nil {
+		return 0
+	}
+	return time.Duration(h.HoldSeconds) * time.Second
+}
+
+// SampleCount returns the number of player counts to average, which is 1 when h is nil or Samples is not set.
+func (h *Hysteresis) SampleCount() int {
+	if h == nil || h.Samples < 1 {
+		return 1
+	}
+	return h.Samples
+}
@@ -0,0 +1,25 @@
+package data
+
+// mapNames are the names of the maps of the rcon map data, as reported by the game in the session. rconv2 keeps the
+// grid of each map, but does not export the names of the maps it knows, which are therefore listed here. Unlike the
+// maps of the generator, this list does not depend on a map being supported by a seeding layout.
+var mapNames = []string{
+	"CARENTAN",
+	"DRIEL",
+	"EL ALAMEIN",
+	"ELSENBORN RIDGE",
+	"FOY",
+	"HILL 400",
+	"HÜRTGEN FOREST",
+	"KHARKOV",
+	"KURSK",
+	"MORTAIN",
+	"OMAHA BEACH",
+	"PURPLE HEART LANE",
+	"REMAGEN",
+	"SAINTE-MÈRE-ÉGLISE",
+	"ST MARIE DU MONT",
+	"STALINGRAD",
+	"TOBRUK",
+	"UTAH BEACH",
+}
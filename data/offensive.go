@@ -0,0 +1,45 @@
+package data
+
+import (
+	"github.com/floriansw/go-hll-rcon/rconv2/api"
+)
+
+const gameModeOffensive = "Offensive"
+
+// geometryModes are the game modes rconv2 does not know the map geometry of, together with the game mode sharing the
+// geometry. Offensive is played on the whole map, with the same grid as Warfare.
+var geometryModes = map[string]string{
+	gameModeOffensive: "Warfare",
+}
+
+// geometry returns a session with the map geometry of si, which rconv2 is able to translate positions with.
+func geometry(si *api.GetSessionResponse) *api.GetSessionResponse {
+	if si == nil {
+		return nil
+	}
+	if mode, ok := geometryModes[si.GameMode]; ok {
+		c := *si
+		c.GameMode = mode
+		return &c
+	}
+	return si
+}
+
+// GridOf returns the grid of the world position p on the map of the given session. Unlike api.WorldPosition.Grid, it
+// knows the geometry of Offensive maps as well.
+func GridOf(si *api.GetSessionResponse, p api.WorldPosition) api.Grid {
+	return p.Grid(geometry(si))
+}
+
+// Attacker returns the side attacking on the map of the given session and reports whether an Offensive is played at
+// all. The session does not tell which side attacks, the Allies are assumed unless OffensiveAttackers of the server
+// configures the map otherwise.
+func (s Server) Attacker(si *api.GetSessionResponse) (Side, bool) {
+	if si == nil || si.GameMode != gameModeOffensive {
+		return "", false
+	}
+	if side, ok := s.OffensiveAttackers[si.MapName]; ok {
+		return side, true
+	}
+	return SideAllies, true
+}
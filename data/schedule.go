@@ -0,0 +1,71 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	// the time zones of the servers do not depend on the time zone database of the system
+	_ "time/tzdata"
+)
+
+// weekdays are the names of the days in a TimeWindow, in the order of time.Weekday.
+var weekdays = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// TimeWindow is a range of the time of the day on some days of the week, e.g., weekday mornings. From and To are
+// times like 06:00, To is not part of the window. A window with To before From ends on the next day, which allows
+// windows like 22:00 to 06:00. The window applies on all days, when Days is empty.
+type TimeWindow struct {
+	// Days are the days the window starts on, e.g., Mon or Sat.
+	Days []string `yaml:"Days,omitempty,flow"`
+	From string   `yaml:"From"`
+	To   string   `yaml:"To"`
+}
+
+// Includes reports whether t, in the time zone the window is meant in, is within the window.
+func (w TimeWindow) Includes(t time.Time) (bool, error) {
+	from, err := minuteOfDay(w.From)
+	if err != nil {
+		return false, err
+	}
+	to, err := minuteOfDay(w.To)
+	if err != nil {
+		return false, err
+	}
+	for _, d := range w.Days {
+		if !slices.Contains(weekdays, d) {
+			return false, fmt.Errorf("unknown day %s, expected one of %s", d, strings.Join(weekdays, ", "))
+		}
+	}
+	now, day := t.Hour()*60+t.Minute(), t.Weekday()
+	if from <= to {
+		return w.on(day) && now >= from && now < to, nil
+	}
+	if now >= from {
+		return w.on(day), nil
+	}
+	// the window started the day before
+	return now < to && w.on((day+6)%7), nil
+}
+
+func (w TimeWindow) on(d time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, weekdays[d])
+}
+
+// minuteOfDay parses a time of the day like 06:00 or 24:00 into the minutes since midnight.
+func minuteOfDay(s string) (int, error) {
+	var h, m int
+	if n, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || n != 2 || len(s) != 5 || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time %q, expected a time of the day like 06:00", s)
+	}
+	return h*60 + m, nil
+}
+
+// Location returns the time zone of the server, which the schedules of the conditions are evaluated in. It is UTC,
+// when the TimeZone of the server is not set or unknown.
+func (s Server) Location() *time.Location {
+	if l, err := time.LoadLocation(s.TimeZone); err == nil {
+		return l
+	}
+	return time.UTC
+}
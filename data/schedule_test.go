@@ -0,0 +1,56 @@
+package data_test
+
+import (
+	"time"
+
+	"github.com/floriansw/hll-geofences/data"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TimeWindow", func() {
+	weekdayMornings := data.TimeWindow{Days: []string{"Mon", "Tue", "Wed", "Thu", "Fri"}, From: "06:00", To: "12:00"}
+	nights := data.TimeWindow{Days: []string{"Fri", "Sat"}, From: "22:00", To: "06:00"}
+	// 2026-10-19 is a Monday
+	at := func(day int, clock string) time.Time {
+		t, err := time.Parse("15:04", clock)
+		Expect(err).ToNot(HaveOccurred())
+		return time.Date(2026, 10, day, t.Hour(), t.Minute(), 0, 0, time.UTC)
+	}
+
+	DescribeTable("Includes", func(w data.TimeWindow, day int, clock string, expected bool) {
+		Expect(w.Includes(at(day, clock))).To(Equal(expected))
+	},
+		Entry("start of the window", weekdayMornings, 19, "06:00", true),
+		Entry("before the window", weekdayMornings, 19, "05:59", false),
+		Entry("end of the window", weekdayMornings, 19, "12:00", false),
+		Entry("other day", weekdayMornings, 18, "08:00", false),
+		Entry("all days", data.TimeWindow{From: "06:00", To: "12:00"}, 18, "08:00", true),
+		Entry("until the end of the day", data.TimeWindow{From: "18:00", To: "24:00"}, 18, "23:59", true),
+		Entry("before midnight of a window ending the next day", nights, 23, "23:00", true),
+		Entry("after midnight of a window ending the next day", nights, 25, "05:00", true),
+		Entry("after midnight of a window starting the day before", nights, 23, "05:00", false),
+		Entry("after the end of a window ending the next day", nights, 24, "06:00", false),
+	)
+
+	It("fails on invalid windows", func() {
+		_, err := data.TimeWindow{From: "6", To: "12:00"}.Includes(time.Now())
+		Expect(err).To(MatchError(`invalid time "6", expected a time of the day like 06:00`))
+		_, err = data.TimeWindow{Days: []string{"Monday"}, From: "06:00", To: "12:00"}.Includes(time.Now())
+		Expect(err).To(MatchError("unknown day Monday, expected one of Sun, Mon, Tue, Wed, Thu, Fri, Sat"))
+	})
+
+	It("matches conditions with any window of the schedule", func() {
+		c := data.Condition{Schedule: []data.TimeWindow{weekdayMornings, nights}}
+		Expect(c.Matches(data.GameState{Time: at(19, "07:00")})).To(BeTrue())
+		Expect(c.Matches(data.GameState{Time: at(24, "01:00")})).To(BeTrue())
+		Expect(c.Matches(data.GameState{Time: at(19, "13:00")})).To(BeFalse())
+	})
+
+	It("evaluates schedules in the time zone of the server", func() {
+		Expect(data.Server{TimeZone: "Europe/Berlin"}.Location().String()).To(Equal("Europe/Berlin"))
+		Expect(data.Server{}.Location()).To(Equal(time.UTC))
+		Expect(data.Server{TimeZone: "Europe/Nowhere"}.Location()).To(Equal(time.UTC))
+	})
+})
@@ -0,0 +1,54 @@
+package data
+
+import (
+	"log/slog"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// State is changed by the tool at runtime and persisted separately from the config, as the config is never written
+// by the tool.
+type State struct {
+	// Profile is the name of the active profile of the config.
+	Profile string `yaml:"Profile,omitempty"`
+	// Paused are the addresses of the servers on which the enforcement of fences is paused.
+	Paused []string `yaml:"Paused,omitempty"`
+
+	path string
+}
+
+// NewState reads the state from path. An empty state is returned when the file does not exist yet.
+func NewState(path string, logger *slog.Logger) (*State, error) {
+	s := &State{path: path}
+	c, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		logger.Info("create-state", "path", path)
+		return s, nil
+	} else if err != nil {
+		return s, err
+	}
+	if err := yaml.Unmarshal(c, s); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// SetPaused adds or removes address to or from Paused.
+func (s *State) SetPaused(address string, paused bool) {
+	s.Paused = slices.DeleteFunc(s.Paused, func(v string) bool {
+		return v == address
+	})
+	if paused {
+		s.Paused = append(s.Paused, address)
+	}
+}
+
+func (s *State) Save() error {
+	c, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, c)
+}
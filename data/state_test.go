@@ -0,0 +1,68 @@
+package data_test
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/floriansw/hll-geofences/data"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("State", func() {
+	var l *slog.Logger
+	var dir string
+
+	BeforeEach(func() {
+		l = slog.New(slog.NewTextHandler(os.Stdout, nil))
+		var err error
+		dir, err = os.MkdirTemp(os.TempDir(), "state")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("starts empty and persists changes", func() {
+		path := filepath.Join(dir, "state.yml")
+		s, err := data.NewState(path, l)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.Profile).To(BeEmpty())
+		Expect(s.Paused).To(BeEmpty())
+
+		s.Profile = "extended"
+		s.SetPaused("127.0.0.1:7779", true)
+		s.SetPaused("127.0.0.1:7780", true)
+		s.SetPaused("127.0.0.1:7779", false)
+		Expect(s.Save()).To(Succeed())
+
+		s, err = data.NewState(path, l)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.Profile).To(Equal("extended"))
+		Expect(s.Paused).To(Equal([]string{"127.0.0.1:7780"}))
+	})
+
+	Describe("Profiles", func() {
+		It("reads profiles relative to the config", func() {
+			Expect(os.WriteFile(filepath.Join(dir, "config.yml"), []byte("Profiles: {extended: extended.yml}\nServers: [{Host: 127.0.0.1, Port: 1}]"), 0600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "extended.yml"), []byte("Servers: [{Host: 127.0.0.1, Port: 2}]"), 0600)).To(Succeed())
+
+			c, err := data.NewConfig(filepath.Join(dir, "config.yml"), l)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c.ProfileNames()).To(Equal([]string{data.DefaultProfile, "extended"}))
+
+			p, err := c.Profile("extended", l)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.Servers[0].Port).To(Equal(2))
+
+			p, err = c.Profile(data.DefaultProfile, l)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(p.Servers[0].Port).To(Equal(1))
+
+			_, err = c.Profile("unknown", l)
+			Expect(err).To(MatchError(data.ErrUnknownProfile))
+		})
+	})
+})
@@ -0,0 +1,433 @@
+package data
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var gameModes = []string{"Warfare", "Skirmish", "Offensive"}
+
+// Finding is a mistake in a config, together with the line of the config it was found in.
+type Finding struct {
+	Line    int
+	Message string
+	// Warning is true for findings, which might be intended, e.g., an AttackerFence without OffensiveAttackers.
+	// Configs with warnings are read anyway.
+	Warning bool
+}
+
+func (f Finding) String() string {
+	if f.Warning {
+		return fmt.Sprintf("line %d: warning: %s", f.Line, f.Message)
+	}
+	return fmt.Sprintf("line %d: %s", f.Line, f.Message)
+}
+
+// ValidationError is returned when reading a config with findings, which are not warnings.
+type ValidationError []Finding
+
+func (v ValidationError) Error() string {
+	var s []string
+	for _, f := range v {
+		s = append(s, f.String())
+	}
+	return "invalid config: " + strings.Join(s, "; ")
+}
+
+var errorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// Validate checks the YAML of a config for mistakes, which would otherwise be accepted silently and result in fences
+// never matching: unknown fields, invalid grids and numpads, unknown condition operators and keys as well as
+// references to unknown conditions, fence groups and maps. The findings are sorted by line.
+func Validate(raw []byte) []Finding {
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return findingsOf(err)
+	}
+	var findings []Finding
+	d := yaml.NewDecoder(bytes.NewReader(raw))
+	d.KnownFields(true)
+	if err := d.Decode(&Config{}); err != nil && !errors.Is(err, io.EOF) {
+		findings = append(findings, findingsOf(err)...)
+	}
+	if len(root.Content) != 0 {
+		v := &validator{}
+		v.config(root.Content[0])
+		findings = append(findings, v.findings...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+	return findings
+}
+
+func findingsOf(err error) []Finding {
+	var messages []string
+	var te *yaml.TypeError
+	if errors.As(err, &te) {
+		messages = te.Errors
+	} else {
+		messages = []string{err.Error()}
+	}
+	var findings []Finding
+	for _, m := range messages {
+		f := Finding{Message: m}
+		if match := errorLine.FindStringSubmatch(m); match != nil {
+			f.Line, _ = strconv.Atoi(match[1])
+			f.Message = match[2]
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+type validator struct {
+	conditions []string
+	groups     []string
+	findings   []Finding
+}
+
+func (v *validator) add(n *yaml.Node, format string, args ...any) {
+	v.findings = append(v.findings, Finding{Line: n.Line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) warn(n *yaml.Node, format string, args ...any) {
+	v.findings = append(v.findings, Finding{Line: n.Line, Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+// mapName reports map names, which the game does not report.
+func (v *validator) mapName(n *yaml.Node) {
+	if !slices.Contains(mapNames, n.Value) {
+		v.add(n, "unknown map name %s", n.Value)
+	}
+}
+
+func (v *validator) config(n *yaml.Node) {
+	conditions, groups := value(n, "Conditions"), value(n, "FenceGroups")
+	for _, p := range pairs(conditions) {
+		v.conditions = append(v.conditions, p[0].Value)
+	}
+	for _, p := range pairs(groups) {
+		v.groups = append(v.groups, p[0].Value)
+	}
+	for _, p := range pairs(value(n, "Profiles")) {
+		if p[0].Value == DefaultProfile {
+			v.add(p[0], "profile name %s is reserved for the config itself", DefaultProfile)
+		}
+	}
+	for _, p := range pairs(conditions) {
+		if p[1].Kind == yaml.ScalarNode {
+			v.add(p[1], "condition %s cannot reference another condition", p[0].Value)
+			continue
+		}
+		v.condition(p[1])
+	}
+	for _, p := range pairs(groups) {
+		v.condition(value(p[1], "Condition"))
+		for _, f := range items(value(p[1], "Fences")) {
+			if g := value(f, "Group"); g != nil {
+				v.add(g, "fence group %s cannot reference another fence group", p[0].Value)
+			}
+			v.fence(f)
+		}
+	}
+	for _, s := range items(value(n, "Servers")) {
+		fences := []string{"AxisFence", "AlliesFence", "AttackerFence", "DefenderFence"}
+		for _, f := range Factions {
+			fences = append(fences, string(f)+"Fence")
+		}
+		var nodes []*yaml.Node
+		for _, name := range fences {
+			for _, f := range items(value(s, name)) {
+				v.fence(f)
+				nodes = append(nodes, f)
+				if g := value(f, "Group"); g != nil {
+					nodes = append(nodes, items(value(value(groups, g.Value), "Fences"))...)
+				}
+			}
+		}
+		v.factions(value(s, "Factions"))
+		v.attackers(value(s, "OffensiveAttackers"))
+		if value(s, "OffensiveAttackers") == nil {
+			for _, p := range pairs(s) {
+				if (p[0].Value == "AttackerFence" || p[0].Value == "DefenderFence") && len(items(p[1])) != 0 {
+					v.warn(p[0], "%s assumes the Allies attack on every Offensive map, set OffensiveAttackers for the maps the Axis attack on", p[0].Value)
+				}
+			}
+		}
+		if tz := value(s, "TimeZone"); tz != nil {
+			if _, err := time.LoadLocation(tz.Value); err != nil {
+				v.add(tz, "unknown time zone %s, expected an IANA time zone like Europe/Berlin", tz.Value)
+			}
+		}
+		v.warnings(s, nodes)
+		v.escalation(value(s, "Escalation"))
+		v.hysteresis(value(s, "Hysteresis"))
+	}
+}
+
+func (v *validator) factions(n *yaml.Node) {
+	for _, p := range pairs(n) {
+		if !slices.Contains(Factions, Faction(p[0].Value)) {
+			v.add(p[0], "unknown faction %s, expected one of %s", p[0].Value, strings.Join(factionNames(), ", "))
+		}
+		v.side(p[1])
+	}
+}
+
+func (v *validator) attackers(n *yaml.Node) {
+	for _, p := range pairs(n) {
+		v.mapName(p[0])
+		v.side(p[1])
+	}
+}
+
+func (v *validator) side(n *yaml.Node) {
+	if side := Side(n.Value); side != SideAxis && side != SideAllies {
+		v.add(n, "unknown side %s, expected %s or %s", n.Value, SideAxis, SideAllies)
+	}
+}
+
+func factionNames() (v []string) {
+	for _, f := range Factions {
+		v = append(v, string(f))
+	}
+	return v
+}
+
+// warnings checks the warning schedule of the server s against the shortest of the grace periods of the server, its
+// sides and the fences, which override it. Warnings never reached with the grace period of a side or a fence are
+// warnings only, as the schedule still applies to the other players.
+func (v *validator) warnings(s *yaml.Node, fences []*yaml.Node) {
+	punishAfter, source := 10, "PunishAfterSeconds"
+	override := func(n *yaml.Node, name string) {
+		if p := value(n, "PunishAfterSeconds"); p != nil {
+			if i, err := strconv.Atoi(p.Value); err == nil && (i < punishAfter || n == s) {
+				punishAfter, source = i, name
+			}
+		}
+	}
+	override(s, "PunishAfterSeconds")
+	override(value(s, "AxisSettings"), "PunishAfterSeconds of AxisSettings")
+	override(value(s, "AlliesSettings"), "PunishAfterSeconds of AlliesSettings")
+	for _, f := range fences {
+		override(f, fmt.Sprintf("PunishAfterSeconds of the fence in line %d", f.Line))
+	}
+	for _, w := range items(value(s, "Warnings")) {
+		a := value(w, "AfterSeconds")
+		if a == nil {
+			continue
+		}
+		i, err := strconv.Atoi(a.Value)
+		switch {
+		case err != nil:
+		case i < 0 || (i >= punishAfter && source == "PunishAfterSeconds"):
+			v.add(a, "AfterSeconds %d is never reached, expected 0-%d (%s)", i, punishAfter-1, source)
+		case i >= punishAfter:
+			v.warn(a, "AfterSeconds %d is never reached, expected 0-%d (%s)", i, punishAfter-1, source)
+		}
+	}
+}
+
+func (v *validator) escalation(n *yaml.Node) {
+	for _, s := range items(value(n, "Steps")) {
+		if c := value(s, "Violations"); c == nil {
+			v.add(s, "escalation step requires Violations")
+		} else if i, err := strconv.Atoi(c.Value); err == nil && i < 1 {
+			v.add(c, "Violations must be at least 1, got %d", i)
+		}
+		a := value(s, "Action")
+		if a == nil {
+			v.add(s, "escalation step requires an Action")
+			continue
+		}
+		if !slices.Contains(actions, a.Value) {
+			v.add(a, "unknown action %s, expected one of %s", a.Value, strings.Join(actions, ", "))
+		}
+		if h := value(s, "Hours"); Action(a.Value) == ActionTempBan && h == nil {
+			v.add(s, "%s requires Hours", ActionTempBan)
+		} else if h != nil {
+			v.number(h, "Hours", 1, 24*365)
+		}
+	}
+}
+
+func (v *validator) hysteresis(n *yaml.Node) {
+	for _, b := range []struct {
+		name         string
+		lower, upper int
+	}{{"Band", 0, 100}, {"HoldSeconds", 0, 3600}, {"Samples", 1, 100}} {
+		if x := value(n, b.name); x != nil {
+			v.number(x, b.name, b.lower, b.upper)
+		}
+	}
+}
+
+func (v *validator) fence(n *yaml.Node) {
+	if x := value(n, "X"); x != nil && !slices.Contains(gridColumns, x.Value) {
+		v.add(x, "invalid X %s, expected one of %s", x.Value, strings.Join(gridColumns, ", "))
+	}
+	if y := value(n, "Y"); y != nil {
+		v.number(y, "Y", 1, 10)
+	}
+	for _, numpad := range items(value(n, "Numpad")) {
+		v.number(numpad, "Numpad", 1, 9)
+	}
+	if r := value(n, "Radius"); r != nil {
+		if numpad := value(r, "Numpad"); numpad != nil {
+			v.number(numpad, "Numpad", 1, 9)
+		}
+		if m := value(r, "Meters"); m == nil {
+			v.add(r, "Radius requires Meters")
+		} else if meters, err := strconv.ParseFloat(m.Value, 64); err == nil && meters <= 0 {
+			v.add(m, "Meters must be greater than 0")
+		}
+		grid := value(r, "Grid")
+		if grid == nil && (value(r, "X") == nil || value(r, "Y") == nil) {
+			v.add(r, "Radius requires either a Grid or X and Y")
+		}
+		if grid != nil {
+			if _, _, err := parseGrid(grid.Value); err != nil {
+				v.add(grid, "%s", err)
+			}
+		} else if numpad := value(r, "Numpad"); numpad != nil && (value(r, "X") != nil || value(r, "Y") != nil) {
+			v.add(numpad, "Numpad of a Radius requires a Grid, it is ignored with X and Y")
+		}
+	}
+	if p := value(n, "Polygon"); p != nil && len(p.Content) < 3 {
+		v.add(p, "Polygon requires at least 3 vertices, got %d", len(p.Content))
+	}
+	if g := value(n, "Group"); g != nil && !slices.Contains(v.groups, g.Value) {
+		v.add(g, "unknown fence group %s", g.Value)
+	}
+	v.condition(value(n, "Condition"))
+}
+
+func (v *validator) condition(n *yaml.Node) {
+	if n == nil {
+		return
+	}
+	if n.Kind == yaml.ScalarNode {
+		if !slices.Contains(v.conditions, n.Value) {
+			v.add(n, "unknown condition %s", n.Value)
+		}
+		return
+	}
+	for _, p := range pairs(n) {
+		if p[0].Value == "Schedule" {
+			v.schedule(p[1])
+			continue
+		}
+		keys, ok := conditionKeys[p[0].Value]
+		if !ok {
+			operators := append(sortedKeys(conditionKeys), "Schedule")
+			slices.Sort(operators)
+			v.add(p[0], "unknown condition operator %s, expected one of %s", p[0].Value, strings.Join(operators, ", "))
+			continue
+		}
+		for _, c := range pairs(p[1]) {
+			if !slices.Contains(keys, c[0].Value) {
+				v.add(c[0], "unknown condition key %s for %s, expected one of %s", c[0].Value, p[0].Value, strings.Join(keys, ", "))
+				continue
+			}
+			if p[0].Value == "Between" {
+				v.between(c[1])
+			}
+			for _, e := range items(c[1]) {
+				if c[0].Value == "map_name" {
+					v.mapName(e)
+				}
+				if c[0].Value == "game_mode" && !slices.Contains(gameModes, e.Value) {
+					v.add(e, "unknown game mode %s, expected one of %s", e.Value, strings.Join(gameModes, ", "))
+				}
+			}
+		}
+	}
+}
+
+func (v *validator) schedule(n *yaml.Node) {
+	for _, w := range items(n) {
+		for _, key := range []string{"From", "To"} {
+			t := value(w, key)
+			if t == nil {
+				v.add(w, "time window requires %s", key)
+			} else if _, err := minuteOfDay(t.Value); err != nil {
+				v.add(t, "%s", err)
+			}
+		}
+		for _, d := range items(value(w, "Days")) {
+			if !slices.Contains(weekdays, d.Value) {
+				v.add(d, "unknown day %s, expected one of %s", d.Value, strings.Join(weekdays, ", "))
+			}
+		}
+	}
+}
+
+func (v *validator) between(n *yaml.Node) {
+	bounds := items(n)
+	if len(bounds) != 2 {
+		v.add(n, "Between requires a lower and an upper bound, got %d values", len(bounds))
+		return
+	}
+	lower, err := strconv.Atoi(bounds[0].Value)
+	if err != nil {
+		return
+	}
+	if upper, err := strconv.Atoi(bounds[1].Value); err == nil && lower > upper {
+		v.add(n, "lower bound %d of Between is greater than upper bound %d", lower, upper)
+	}
+}
+
+func (v *validator) number(n *yaml.Node, name string, lower, upper int) {
+	if i, err := strconv.Atoi(n.Value); err == nil && (i < lower || i > upper) {
+		v.add(n, "invalid %s %d, expected %d-%d", name, i, lower, upper)
+	}
+}
+
+// value returns the value of key in the mapping n, or nil if n is not a mapping or does not contain key.
+func value(n *yaml.Node, key string) *yaml.Node {
+	for _, p := range pairs(n) {
+		if p[0].Value == key {
+			return p[1]
+		}
+	}
+	return nil
+}
+
+// pairs returns the key and value nodes of the mapping n.
+func pairs(n *yaml.Node) (v [][2]*yaml.Node) {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		v = append(v, [2]*yaml.Node{n.Content[i], n.Content[i+1]})
+	}
+	return v
+}
+
+// items returns the items of the sequence n.
+func items(n *yaml.Node) []*yaml.Node {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return n.Content
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var v []string
+	for k := range m {
+		v = append(v, k)
+	}
+	slices.Sort(v)
+	return v
+}
@@ -0,0 +1,309 @@
+package data_test
+
+import (
+	"github.com/floriansw/hll-geofences/data"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate", func() {
+	It("accepts a valid config", func() {
+		Expect(data.Validate([]byte(`
+Conditions:
+  seeding:
+    Equals:
+      map_name: [CARENTAN, FOY]
+      game_mode: [Warfare]
+    LessThan:
+      player_count: 50
+  mornings:
+    Schedule:
+      - Days: [Mon, Tue, Wed, Thu, Fri]
+        From: "06:00"
+        To: "17:00"
+      - From: "22:00"
+        To: "02:00"
+  live:
+    NotEquals:
+      server_name: [Event server]
+    Between:
+      player_count: [50, 100]
+    GreaterThan:
+      queue_count: 0
+    LessThan:
+      team_imbalance: 10
+FenceGroups:
+  midcap:
+    Condition: seeding
+    Fences:
+      - Grids: [E1-F10]
+Servers:
+  - Host: 127.0.0.1
+    Port: 7779
+    Password: secret
+    TimeZone: Europe/Berlin
+    AxisFence:
+      - X: A
+        "Y": 10
+        Numpad: [1, 9]
+      - Group: midcap
+      - Radius:
+          Grid: E5
+          Numpad: 5
+          Meters: 150
+    AlliesFence:
+      - Polygon:
+          - {X: 0, "Y": 0}
+          - {X: 1, "Y": 0}
+          - {X: 1, "Y": 1}
+        Condition: seeding
+    DAKFence:
+      - X: B
+    Factions:
+      DAK: Axis
+    Escalation:
+      WindowMinutes: 120
+      Steps:
+        - {Violations: 1, Action: punish}
+        - {Violations: 3, Action: kick, Reason: Stay inside the play area}
+        - {Violations: 5, Action: temp-ban, Hours: 2}
+`))).To(BeEmpty())
+	})
+
+	DescribeTable("reports findings with their line", func(config string, expected ...data.Finding) {
+		Expect(data.Validate([]byte(config))).To(Equal(expected))
+	},
+		Entry("invalid grid letter", `
+Servers:
+  - AxisFence:
+      - X: K
+`, data.Finding{Line: 4, Message: "invalid X K, expected one of A, B, C, D, E, F, G, H, I, J"}),
+		Entry("invalid grid row", `
+Servers:
+  - AxisFence:
+      - "Y": 11
+`, data.Finding{Line: 4, Message: "invalid Y 11, expected 1-10"}),
+		Entry("invalid numpad", `
+Servers:
+  - AlliesFence:
+      - X: A
+        Numpad: [1, 0]
+`, data.Finding{Line: 5, Message: "invalid Numpad 0, expected 1-9"}),
+		Entry("unknown condition key", `
+Servers:
+  - AlliesFence:
+      - X: A
+        Condition:
+          LessThan:
+            player_cont: 50
+`, data.Finding{Line: 7, Message: "unknown condition key player_cont for LessThan, expected one of allies_player_count, axis_player_count, max_player_count, player_count, queue_count, team_imbalance, vip_queue_count"}),
+		Entry("invalid bounds of Between", `
+Conditions:
+  seeding:
+    Between:
+      player_count: [50]
+      queue_count: [5, 1]
+`,
+			data.Finding{Line: 5, Message: "Between requires a lower and an upper bound, got 1 values"},
+			data.Finding{Line: 6, Message: "lower bound 5 of Between is greater than upper bound 1"},
+		),
+		Entry("invalid schedule", `
+Conditions:
+  mornings:
+    Schedule:
+      - Days: [Mon, Thursday]
+        From: "6:00"
+      - From: "22:00"
+        To: "25:00"
+Servers:
+  - TimeZone: Europe/Paris
+  - TimeZone: Europe/Nowhere
+`,
+			data.Finding{Line: 5, Message: "time window requires To"},
+			data.Finding{Line: 5, Message: `unknown day Thursday, expected one of Sun, Mon, Tue, Wed, Thu, Fri, Sat`},
+			data.Finding{Line: 6, Message: `invalid time "6:00", expected a time of the day like 06:00`},
+			data.Finding{Line: 8, Message: `invalid time "25:00", expected a time of the day like 06:00`},
+			data.Finding{Line: 11, Message: "unknown time zone Europe/Nowhere, expected an IANA time zone like Europe/Berlin"},
+		),
+		Entry("unknown map name of NotEquals", `
+Conditions:
+  seeding:
+    NotEquals:
+      map_name: [PARIS]
+`, data.Finding{Line: 5, Message: "unknown map name PARIS"}),
+		Entry("unknown condition operator", `
+Conditions:
+  seeding:
+    LesThan:
+      player_count: 50
+`, data.Finding{Line: 4, Message: "unknown condition operator LesThan, expected one of Between, Equals, GreaterThan, LessThan, NotEquals, Schedule"}),
+		Entry("misspelled map name", `
+Conditions:
+  seeding:
+    Equals:
+      map_name:
+        - CARENTAN
+        - CARENTEN
+`, data.Finding{Line: 7, Message: "unknown map name CARENTEN"}),
+		Entry("unknown game mode", `
+Conditions:
+  seeding:
+    Equals:
+      game_mode: [Warfar]
+`, data.Finding{Line: 5, Message: "unknown game mode Warfar, expected one of Warfare, Skirmish, Offensive"}),
+		Entry("unknown field", `
+Servers:
+  - AxisFence:
+      - X: A
+        Numpads: [1]
+`, data.Finding{Line: 5, Message: "field Numpads not found in type data.Fence"}),
+		Entry("invalid grid range", `
+Servers:
+  - AxisFence:
+      - Grids: [A1-K3]
+`, data.Finding{Line: 4, Message: `invalid grid "K3", expected a column A-J followed by a row 1-10`}),
+		Entry("radius without meters", `
+Servers:
+  - AxisFence:
+      - Radius:
+          Grid: A1
+`, data.Finding{Line: 5, Message: "Radius requires Meters"}),
+		Entry("invalid grid of a radius", `
+Servers:
+  - AxisFence:
+      - Radius:
+          Grid: K5
+          Meters: 100
+`, data.Finding{Line: 5, Message: `invalid grid "K5", expected a column A-J followed by a row 1-10`}),
+		Entry("numpad of a radius in world coordinates", `
+Servers:
+  - AxisFence:
+      - Radius:
+          X: 1000
+          Y: 1000
+          Numpad: 3
+          Meters: 100
+`, data.Finding{Line: 7, Message: "Numpad of a Radius requires a Grid, it is ignored with X and Y"}),
+		Entry("unknown escalation action", `
+Servers:
+  - Escalation:
+      Steps:
+        - {Violations: 2, Action: ban}
+`, data.Finding{Line: 5, Message: "unknown action ban, expected one of punish, kick, temp-ban"}),
+		Entry("temp-ban without hours", `
+Servers:
+  - Escalation:
+      Steps:
+        - Violations: 0
+          Action: temp-ban
+`,
+			data.Finding{Line: 5, Message: "Violations must be at least 1, got 0"},
+			data.Finding{Line: 5, Message: "temp-ban requires Hours"},
+		),
+		Entry("hysteresis out of range", `
+Servers:
+  - Hysteresis:
+      Band: 10
+      HoldSeconds: 7200
+      Samples: 0
+`,
+			data.Finding{Line: 5, Message: "invalid HoldSeconds 7200, expected 0-3600"},
+			data.Finding{Line: 6, Message: "invalid Samples 0, expected 1-100"},
+		),
+		Entry("warning after punishment", `
+Servers:
+  - PunishAfterSeconds: 8
+    Warnings:
+      - AfterSeconds: 0
+      - AfterSeconds: 8
+`, data.Finding{Line: 6, Message: "AfterSeconds 8 is never reached, expected 0-7 (PunishAfterSeconds)"}),
+		Entry("warning after the punishment of a side", `
+Servers:
+  - AxisSettings:
+      PunishAfterSeconds: 5
+    Warnings:
+      - AfterSeconds: 0
+      - AfterSeconds: 5
+`, data.Finding{Line: 7, Message: "AfterSeconds 5 is never reached, expected 0-4 (PunishAfterSeconds of AxisSettings)", Warning: true}),
+		Entry("warning after the punishment of a fence", `
+FenceGroups:
+  hq:
+    Fences:
+      - X: A
+        PunishAfterSeconds: 3
+Servers:
+  - PunishAfterSeconds: 20
+    AlliesFence:
+      - X: E
+        PunishAfterSeconds: 8
+      - Group: hq
+    Warnings:
+      - AfterSeconds: 0
+      - AfterSeconds: 5
+      - AfterSeconds: 8
+`,
+			data.Finding{Line: 15, Message: "AfterSeconds 5 is never reached, expected 0-2 (PunishAfterSeconds of the fence in line 5)", Warning: true},
+			data.Finding{Line: 16, Message: "AfterSeconds 8 is never reached, expected 0-2 (PunishAfterSeconds of the fence in line 5)", Warning: true},
+		),
+		Entry("invalid fence of a faction", `
+Servers:
+  - DAKFence:
+      - X: K
+`, data.Finding{Line: 4, Message: "invalid X K, expected one of A, B, C, D, E, F, G, H, I, J"}),
+		Entry("unknown faction and side", `
+Servers:
+  - Factions:
+      ITA: Axis
+      DAK: Neutral
+`,
+			data.Finding{Line: 4, Message: "unknown faction ITA, expected one of GER, US, RUS, GB, DAK, B8A"},
+			data.Finding{Line: 5, Message: "unknown side Neutral, expected Axis or Allies"},
+		),
+		Entry("unknown map of an offensive", `
+Servers:
+  - OffensiveAttackers:
+      CARENTAN: Axis
+      PARIS: Allies
+      FOY: Attacker
+`,
+			data.Finding{Line: 5, Message: "unknown map name PARIS"},
+			data.Finding{Line: 6, Message: "unknown side Attacker, expected Axis or Allies"},
+		),
+		Entry("invalid fence of the attackers", `
+Servers:
+  - AttackerFence:
+      - "Y": 11
+`,
+			data.Finding{Line: 3, Message: "AttackerFence assumes the Allies attack on every Offensive map, set OffensiveAttackers for the maps the Axis attack on", Warning: true},
+			data.Finding{Line: 4, Message: "invalid Y 11, expected 1-10"},
+		),
+		Entry("fences of the defenders without attackers", `
+Servers:
+  - DefenderFence:
+      - X: A
+  - DefenderFence:
+      - X: A
+    OffensiveAttackers:
+      STALINGRAD: Axis
+`, data.Finding{Line: 3, Message: "DefenderFence assumes the Allies attack on every Offensive map, set OffensiveAttackers for the maps the Axis attack on", Warning: true}),
+		Entry("multiple findings in order", `
+Servers:
+  - AxisFence:
+      - X: K
+    AlliesFence:
+      - X: A
+        "Y": 0
+        Condition: unknown
+`,
+			data.Finding{Line: 4, Message: "invalid X K, expected one of A, B, C, D, E, F, G, H, I, J"},
+			data.Finding{Line: 7, Message: "invalid Y 0, expected 1-10"},
+			data.Finding{Line: 8, Message: "unknown condition unknown"},
+		),
+		Entry("invalid yaml", `
+Servers:
+  - X: A
+   Y: 1
+`, data.Finding{Line: 2, Message: "did not find expected '-' indicator"}),
+	)
+})
@@ -0,0 +1,67 @@
+package data
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is the time to wait for more changes of the config before notifying about a change. Editors usually
+// write a file in multiple steps.
+var watchDebounce = 500 * time.Millisecond
+
+// Watch notifies on the returned channel whenever one of the configs at paths changes, e.g., the config and the one of
+// the active profile, until ctx is done. The directories of the configs are watched instead of the files themselves,
+// as many editors replace a file when saving it.
+func Watch(ctx context.Context, paths []string, logger *slog.Logger) (<-chan struct{}, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	names, dirs := map[string]bool{}, map[string]bool{}
+	for _, path := range paths {
+		name := filepath.Clean(path)
+		names[name] = true
+		if dirs[filepath.Dir(name)] {
+			continue
+		}
+		dirs[filepath.Dir(name)] = true
+		if err := w.Add(filepath.Dir(name)); err != nil {
+			_ = w.Close()
+			return nil, err
+		}
+	}
+	changes := make(chan struct{}, 1)
+	go func() {
+		defer w.Close()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if names[filepath.Clean(e.Name)] && e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					debounce = time.After(watchDebounce)
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				logger.Error("watch-config", "error", err)
+			case <-debounce:
+				debounce = nil
+				select {
+				case changes <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
@@ -0,0 +1,64 @@
+package data_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/floriansw/hll-geofences/data"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Watch", func() {
+	var dir, path, profile string
+	var changes <-chan struct{}
+	var cancel context.CancelFunc
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp(os.TempDir(), "config")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "config.yml")
+		Expect(os.WriteFile(path, []byte("Servers: []"), 0600)).To(Succeed())
+		Expect(os.Mkdir(filepath.Join(dir, "profiles"), 0700)).To(Succeed())
+		profile = filepath.Join(dir, "profiles", "seeding.yml")
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		changes, err = data.Watch(ctx, []string{path, profile}, slog.New(slog.NewTextHandler(os.Stdout, nil)))
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		cancel()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("notifies when the config is written", func() {
+		Expect(os.WriteFile(path, []byte("Servers: [{Host: 127.0.0.1, Port: 7779}]"), 0600)).To(Succeed())
+
+		Eventually(changes, 3*time.Second).Should(Receive())
+	})
+
+	It("notifies when the config is replaced", func() {
+		Expect(os.WriteFile(path+".new", []byte("Servers: [{Host: 127.0.0.1, Port: 7779}]"), 0600)).To(Succeed())
+		Expect(os.Rename(path+".new", path)).To(Succeed())
+
+		Eventually(changes, 3*time.Second).Should(Receive())
+	})
+
+	It("notifies when the config of the profile is written", func() {
+		Expect(os.WriteFile(profile, []byte("Servers: [{Host: 127.0.0.1, Port: 7779}]"), 0600)).To(Succeed())
+
+		Eventually(changes, 3*time.Second).Should(Receive())
+	})
+
+	It("does not notify about other files", func() {
+		Expect(os.WriteFile(filepath.Join(dir, "other.yml"), []byte("{}"), 0600)).To(Succeed())
+
+		Consistently(changes, time.Second).ShouldNot(Receive())
+	})
+})
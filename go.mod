@@ -4,15 +4,15 @@ go 1.24.1
 
 require (
 	github.com/floriansw/go-hll-rcon v0.0.0-20250501210100-80746ddffb63
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/joho/godotenv v1.5.1
 	github.com/onsi/ginkgo v1.16.5
 	github.com/onsi/gomega v1.36.2
 	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/fsnotify/fsnotify v1.4.9 // indirect
 	github.com/google/go-cmp v0.6.0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
 	github.com/nxadm/tail v1.4.8 // indirect
 	golang.org/x/net v0.33.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
@@ -0,0 +1,134 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventWarning    EventType = "warning"
+	EventPunish     EventType = "punish"
+	EventKick       EventType = "kick"
+	EventTempBan    EventType = "temp-ban"
+	EventBackInside EventType = "back-inside"
+)
+
+// Position is the world position of a player, in the same unit the game reports it.
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// Event is something that happened to a player because of the fences.
+type Event struct {
+	Time       time.Time `json:"time"`
+	Type       EventType `json:"type"`
+	Server     string    `json:"server"`
+	MapName    string    `json:"map_name"`
+	GameMode   string    `json:"game_mode,omitempty"`
+	PlayerID   string    `json:"player_id"`
+	PlayerName string    `json:"player_name"`
+	Team       string    `json:"team"`
+	Grid       string    `json:"grid"`
+	Position   Position  `json:"position"`
+}
+
+// Recorder records events.
+type Recorder interface {
+	Record(e Event) error
+}
+
+// Store records events into a file with one JSON object per line. The file is only ever appended to.
+type Store struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewStore opens the file at path for appending events, it is created if it does not exist.
+func NewStore(path string) (*Store, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{f: f}, nil
+}
+
+func (s *Store) Record(e Event) error {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(raw, '\n'))
+	return err
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// Filter selects events. Empty fields match every event.
+type Filter struct {
+	// Player matches the ID or, case-insensitive, the name of the player.
+	Player string
+	// MapName matches the map name case-insensitive.
+	MapName string
+	Server  string
+	Since   time.Time
+	Until   time.Time
+}
+
+func (f Filter) Matches(e Event) bool {
+	if f.Player != "" && e.PlayerID != f.Player && !strings.EqualFold(e.PlayerName, f.Player) {
+		return false
+	}
+	if f.MapName != "" && !strings.EqualFold(e.MapName, f.MapName) {
+		return false
+	}
+	if f.Server != "" && e.Server != f.Server {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Query returns the events of the file at path matching f, in the order they were recorded. A missing file has no
+// events, lines which cannot be read are skipped.
+func Query(path string, f Filter) ([]Event, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var v []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if f.Matches(e) {
+			v = append(v, e)
+		}
+	}
+	return v, scanner.Err()
+}
@@ -0,0 +1,78 @@
+package history_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/floriansw/hll-geofences/history"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var dir, path string
+
+	yesterday := time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC)
+	events := []history.Event{
+		{Time: yesterday, Type: history.EventWarning, Server: "127.0.0.1:7779", MapName: "FOY", PlayerID: "1", PlayerName: "Able", Team: "Axis", Grid: "E5"},
+		{Time: yesterday.Add(10 * time.Second), Type: history.EventPunish, Server: "127.0.0.1:7779", MapName: "FOY", PlayerID: "1", PlayerName: "Able", Team: "Axis", Grid: "E5"},
+		{Time: yesterday.Add(time.Hour), Type: history.EventKick, Server: "127.0.0.1:7780", MapName: "CARENTAN", PlayerID: "2", PlayerName: "Baker", Team: "Allies", Grid: "A1", Position: history.Position{X: 1, Y: 2, Z: 3}},
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp(os.TempDir(), "history")
+		Expect(err).ToNot(HaveOccurred())
+		path = filepath.Join(dir, "history.jsonl")
+
+		s, err := history.NewStore(path)
+		Expect(err).ToNot(HaveOccurred())
+		for _, e := range events {
+			Expect(s.Record(e)).To(Succeed())
+		}
+		Expect(s.Close()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("appends to an existing history", func() {
+		s, err := history.NewStore(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.Record(history.Event{Time: yesterday, Type: history.EventBackInside, PlayerID: "3"})).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+
+		v, err := history.Query(path, history.Filter{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v).To(HaveLen(4))
+		Expect(v[:3]).To(Equal(events))
+	})
+
+	It("has no events without a file", func() {
+		v, err := history.Query(filepath.Join(dir, "missing.jsonl"), history.Filter{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(v).To(BeEmpty())
+	})
+
+	DescribeTable("Query", func(f history.Filter, expected ...int) {
+		v, err := history.Query(path, f)
+		Expect(err).ToNot(HaveOccurred())
+		var want []history.Event
+		for _, i := range expected {
+			want = append(want, events[i])
+		}
+		Expect(v).To(Equal(want))
+	},
+		Entry("everything", history.Filter{}, 0, 1, 2),
+		Entry("by player id", history.Filter{Player: "2"}, 2),
+		Entry("by player name", history.Filter{Player: "able"}, 0, 1),
+		Entry("by map", history.Filter{MapName: "foy"}, 0, 1),
+		Entry("by server", history.Filter{Server: "127.0.0.1:7780"}, 2),
+		Entry("since", history.Filter{Since: yesterday.Add(10 * time.Second)}, 1, 2),
+		Entry("until", history.Filter{Until: yesterday.Add(10 * time.Second)}, 0),
+		Entry("combined", history.Filter{MapName: "FOY", Since: yesterday, Until: yesterday.Add(time.Minute), Player: "Able"}, 0, 1),
+	)
+})
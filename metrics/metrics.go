@@ -0,0 +1,260 @@
+// Package metrics implements counters, gauges and histograms, which are exposed in the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry is a set of metrics exposed together.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// Default is the registry the metrics of this package are registered with.
+var Default = &Registry{}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes all metrics of the registry in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	for _, m := range r.metrics {
+		m.write(bw)
+	}
+	return bw.Flush()
+}
+
+// Handler serves the metrics of the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = r.Write(w)
+	})
+}
+
+// vec is a metric with a series for each combination of label values.
+type vec[T any] struct {
+	name, help, kind string
+	labels           []string
+
+	mu     sync.Mutex
+	series map[string]*series[T]
+}
+
+type series[T any] struct {
+	values []string
+	v      T
+}
+
+func newVec[T any](kind, name, help string, labels []string) *vec[T] {
+	return &vec[T]{name: name, help: help, kind: kind, labels: labels, series: map[string]*series[T]{}}
+}
+
+// with calls f with the value of the series of the given label values, which is created when it does not exist yet.
+func (v *vec[T]) with(values []string, init func() T, f func(*T)) {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series[T]{values: slices.Clone(values), v: init()}
+		v.series[key] = s
+	}
+	f(&s.v)
+}
+
+// DeleteLabel removes all series, which have the label with the given name set to value.
+func (v *vec[T]) DeleteLabel(name, value string) {
+	i := slices.Index(v.labels, name)
+	if i < 0 {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for key, s := range v.series {
+		if s.values[i] == value {
+			delete(v.series, key)
+		}
+	}
+}
+
+// sorted returns the series ordered by their label values.
+func (v *vec[T]) sorted() []*series[T] {
+	var s []*series[T]
+	for _, e := range v.series {
+		s = append(s, e)
+	}
+	slices.SortFunc(s, func(a, b *series[T]) int {
+		return slices.Compare(a.values, b.values)
+	})
+	return s
+}
+
+func (v *vec[T]) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.kind)
+}
+
+// labelString formats the labels of a series, with extra label pairs appended.
+func (v *vec[T]) labelString(values []string, extra ...string) string {
+	var pairs []string
+	for i, l := range v.labels {
+		pairs = append(pairs, l+`="`+escape(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escape(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Counter is a value which only goes up, e.g., the number of warnings sent.
+type Counter struct {
+	*vec[float64]
+}
+
+func NewCounter(r *Registry, name, help string, labels ...string) *Counter {
+	c := &Counter{newVec[float64]("counter", name, help, labels)}
+	r.register(c)
+	return c
+}
+
+// Inc increments the counter of the given label values by one.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *Counter) Add(delta float64, values ...string) {
+	c.with(values, zero, func(v *float64) {
+		*v += delta
+	})
+}
+
+func (c *Counter) write(w io.Writer) {
+	writeValues(w, c.vec)
+}
+
+// Gauge is a value which goes up and down, e.g., the number of players outside of the fences.
+type Gauge struct {
+	*vec[float64]
+}
+
+func NewGauge(r *Registry, name, help string, labels ...string) *Gauge {
+	g := &Gauge{newVec[float64]("gauge", name, help, labels)}
+	r.register(g)
+	return g
+}
+
+// Set sets the gauge of the given label values to value.
+func (g *Gauge) Set(value float64, values ...string) {
+	g.with(values, zero, func(v *float64) {
+		*v = value
+	})
+}
+
+func (g *Gauge) write(w io.Writer) {
+	writeValues(w, g.vec)
+}
+
+func zero() float64 {
+	return 0
+}
+
+func writeValues(w io.Writer, v *vec[float64]) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.header(w)
+	for _, s := range v.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", v.name, v.labelString(s.values), formatFloat(s.v))
+	}
+}
+
+// Histogram counts observations, e.g., the latency of requests, in buckets.
+type Histogram struct {
+	*vec[histogram]
+	buckets []float64
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// DefaultBuckets are buckets suitable for latencies in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// NewHistogram creates a histogram with the given upper bounds of its buckets, which need to be sorted.
+func NewHistogram(r *Registry, name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{vec: newVec[histogram]("histogram", name, help, labels), buckets: buckets}
+	r.register(h)
+	return h
+}
+
+// Observe adds value to the histogram of the given label values.
+func (h *Histogram) Observe(value float64, values ...string) {
+	h.with(values, func() histogram {
+		return histogram{counts: make([]uint64, len(h.buckets))}
+	}, func(v *histogram) {
+		for i, b := range h.buckets {
+			if value <= b {
+				v.counts[i]++
+			}
+		}
+		v.count++
+		v.sum += value
+	})
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w)
+	for _, s := range h.sorted() {
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(s.values, "le", formatFloat(b)), s.v.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(s.values, "le", "+Inf"), s.v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelString(s.values), formatFloat(s.v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(s.values), s.v.count)
+	}
+}
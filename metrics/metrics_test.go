@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"strings"
+
+	"github.com/floriansw/hll-geofences/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var r *metrics.Registry
+
+	BeforeEach(func() {
+		r = &metrics.Registry{}
+	})
+
+	write := func() string {
+		var b strings.Builder
+		Expect(r.Write(&b)).To(Succeed())
+		return b.String()
+	}
+
+	It("writes counters sorted by their labels", func() {
+		c := metrics.NewCounter(r, "warnings_total", "Number of warnings.", "server")
+		c.Inc("b:1")
+		c.Inc("a:1")
+		c.Add(2, "b:1")
+		Expect(write()).To(Equal(`# HELP warnings_total Number of warnings.
+# TYPE warnings_total counter
+warnings_total{server="a:1"} 1
+warnings_total{server="b:1"} 3
+`))
+	})
+
+	It("writes gauges and deletes series by label", func() {
+		g := metrics.NewGauge(r, "current_map", "The current map.", "server", "map_name")
+		g.Set(1, "a:1", "FOY")
+		g.Set(1, "b:1", "CARENTAN")
+		g.DeleteLabel("server", "a:1")
+		g.Set(1, "a:1", `SAINTE-MÈRE-"ÉGLISE"`)
+		Expect(write()).To(Equal(`# HELP current_map The current map.
+# TYPE current_map gauge
+current_map{server="a:1",map_name="SAINTE-MÈRE-\"ÉGLISE\""} 1
+current_map{server="b:1",map_name="CARENTAN"} 1
+`))
+	})
+
+	It("writes histograms with cumulative buckets", func() {
+		h := metrics.NewHistogram(r, "latency_seconds", "Latency.", []float64{0.1, 1}, "server")
+		h.Observe(0.05, "a:1")
+		h.Observe(0.5, "a:1")
+		h.Observe(5, "a:1")
+		Expect(write()).To(Equal(`# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{server="a:1",le="0.1"} 1
+latency_seconds_bucket{server="a:1",le="1"} 2
+latency_seconds_bucket{server="a:1",le="+Inf"} 3
+latency_seconds_sum{server="a:1"} 5.55
+latency_seconds_count{server="a:1"} 3
+`))
+	})
+
+	It("writes metrics without labels", func() {
+		metrics.NewGauge(r, "up", "Whether it is up.").Set(1)
+		Expect(write()).To(ContainSubstring("\nup 1\n"))
+	})
+})
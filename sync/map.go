@@ -2,28 +2,62 @@ package sync
 
 import "sync"
 
-type Map[K, V any] struct {
-	m sync.Map
+// Map is a map, which is safe for concurrent use.
+type Map[K comparable, V any] struct {
+	mu sync.Mutex
+	m  map[K]V
 }
 
 func (m *Map[K, V]) Load(k K) (v V, ok bool) {
-	value, ok := m.m.Load(k)
-	if !ok {
-		return v, ok
-	}
-	return value.(V), ok
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok = m.m[k]
+	return v, ok
 }
 
 func (m *Map[K, V]) Store(k K, v V) {
-	m.m.Store(k, v)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.m == nil {
+		m.m = map[K]V{}
+	}
+	m.m[k] = v
 }
 
 func (m *Map[K, V]) Delete(k K) {
-	m.m.Delete(k)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.m, k)
+}
+
+// Update atomically replaces the value of k with the one returned by f, which is called with the current value of k
+// and whether it exists. The key is deleted when f returns false. f must not call any method of the map.
+func (m *Map[K, V]) Update(k K, f func(v V, ok bool) (V, bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.m[k]
+	v, keep := f(v, ok)
+	if !keep {
+		delete(m.m, k)
+		return
+	}
+	if m.m == nil {
+		m.m = map[K]V{}
+	}
+	m.m[k] = v
 }
 
+// Range calls f for each key and value of a copy of the map, until f returns false. f may call any method of the map.
 func (m *Map[K, V]) Range(f func(k K, v V) bool) {
-	m.m.Range(func(k, v interface{}) bool {
-		return f(k.(K), v.(V))
-	})
+	m.mu.Lock()
+	c := make(map[K]V, len(m.m))
+	for k, v := range m.m {
+		c[k] = v
+	}
+	m.mu.Unlock()
+	for k, v := range c {
+		if !f(k, v) {
+			return
+		}
+	}
 }
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+
+	"github.com/floriansw/go-hll-rcon/rconv2"
+	"github.com/floriansw/go-hll-rcon/rconv2/api"
+)
+
+// Client are the RCON commands a worker sends to its server.
+type Client interface {
+	SessionInfo(ctx context.Context) (*api.GetSessionResponse, error)
+	Players(ctx context.Context) (*api.GetPlayersResponse, error)
+	MessagePlayer(ctx context.Context, playerId, message string) error
+	PunishPlayer(ctx context.Context, playerId, reason string) error
+	Kick(ctx context.Context, playerId, reason string) error
+	TempBan(ctx context.Context, playerId string, duration int32, reason, adminName string) error
+	// Close closes the connections of the client, it must not be used afterwards.
+	Close()
+}
+
+// poolClient sends each command with a connection of the pool.
+type poolClient struct {
+	pool *rconv2.ConnectionPool
+}
+
+// NewPoolClient returns a Client sending its commands with the connections of pool.
+func NewPoolClient(pool *rconv2.ConnectionPool) Client {
+	return &poolClient{pool: pool}
+}
+
+func (p *poolClient) Close() {
+	p.pool.Shutdown()
+}
+
+// with runs f with a connection of the pool and returns the connection together with the error of f, so that broken
+// connections are retired. Unlike rconv2.ConnectionPool.WithConnection, it returns the error of f.
+func (p *poolClient) with(ctx context.Context, f func(c *rconv2.Connection) error) error {
+	c, err := p.pool.Get(ctx)
+	if err != nil {
+		return err
+	}
+	err = f(c)
+	p.pool.Return(c, err)
+	return err
+}
+
+func (p *poolClient) SessionInfo(ctx context.Context) (si *api.GetSessionResponse, err error) {
+	err = p.with(ctx, func(c *rconv2.Connection) (err error) {
+		si, err = c.SessionInfo(ctx)
+		return
+	})
+	return
+}
+
+func (p *poolClient) Players(ctx context.Context) (players *api.GetPlayersResponse, err error) {
+	err = p.with(ctx, func(c *rconv2.Connection) (err error) {
+		players, err = c.Players(ctx)
+		return
+	})
+	return
+}
+
+func (p *poolClient) MessagePlayer(ctx context.Context, playerId, message string) error {
+	return p.with(ctx, func(c *rconv2.Connection) error {
+		return c.MessagePlayer(ctx, playerId, message)
+	})
+}
+
+func (p *poolClient) PunishPlayer(ctx context.Context, playerId, reason string) error {
+	return p.with(ctx, func(c *rconv2.Connection) error {
+		return c.PunishPlayer(ctx, playerId, reason)
+	})
+}
+
+func (p *poolClient) Kick(ctx context.Context, playerId, reason string) error {
+	return p.with(ctx, func(c *rconv2.Connection) error {
+		return c.Kick(ctx, playerId, reason)
+	})
+}
+
+func (p *poolClient) TempBan(ctx context.Context, playerId string, duration int32, reason, adminName string) error {
+	return p.with(ctx, func(c *rconv2.Connection) error {
+		return c.TempBan(ctx, playerId, duration, reason, adminName)
+	})
+}
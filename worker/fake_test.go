@@ -0,0 +1,188 @@
+package worker
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/floriansw/go-hll-rcon/rconv2/api"
+)
+
+// fakeClock is a Clock, which only moves forward with Advance.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+	timers  []fakeTimer
+}
+
+type fakeTicker struct {
+	clock    *fakeClock
+	c        chan time.Time
+	interval time.Duration
+	next     time.Time
+	stopped  bool
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 10, 17, 20, 0, 0, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) NewTicker(d time.Duration) Ticker {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{clock: f, c: make(chan time.Time, 1), interval: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+func (f *fakeClock) After(d time.Duration) <-chan time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := fakeTimer{at: f.now.Add(d), c: make(chan time.Time, 1)}
+	f.timers = append(f.timers, t)
+	return t.c
+}
+
+// Advance moves the clock forward by d and fires the tickers and timers, which are due. Like time.Ticker, ticks are
+// dropped when the previous one was not received yet.
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	for _, t := range f.tickers {
+		for !t.stopped && !t.next.After(f.now) {
+			select {
+			case t.c <- t.next:
+			default:
+			}
+			t.next = t.next.Add(t.interval)
+		}
+	}
+	f.timers = slices.DeleteFunc(f.timers, func(t fakeTimer) bool {
+		if t.at.After(f.now) {
+			return false
+		}
+		t.c <- t.at
+		return true
+	})
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.stopped = true
+}
+
+// command is an RCON command the fakeServer received.
+type command struct {
+	Name     string
+	PlayerId string
+	Message  string
+}
+
+// fakeServer is an in-memory game server implementing Client.
+type fakeServer struct {
+	mu       sync.Mutex
+	session  api.GetSessionResponse
+	players  []api.GetPlayerResponse
+	commands []command
+	// err fails the polls of the session and the players, when it is set.
+	err    error
+	closed bool
+}
+
+func (f *fakeServer) SetMap(name, mode string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.session.MapName, f.session.GameMode = name, mode
+}
+
+func (f *fakeServer) SetPlayers(players ...api.GetPlayerResponse) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.players = slices.Clone(players)
+	f.session.PlayerCount = len(players)
+}
+
+func (f *fakeServer) SetError(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *fakeServer) Closed() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+func (f *fakeServer) Commands() []command {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.commands)
+}
+
+func (f *fakeServer) add(c command) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, c)
+	return nil
+}
+
+func (f *fakeServer) SessionInfo(context.Context) (*api.GetSessionResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	s := f.session
+	return &s, nil
+}
+
+func (f *fakeServer) Players(context.Context) (*api.GetPlayersResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &api.GetPlayersResponse{Players: slices.Clone(f.players)}, nil
+}
+
+func (f *fakeServer) MessagePlayer(_ context.Context, playerId, message string) error {
+	return f.add(command{Name: "message", PlayerId: playerId, Message: message})
+}
+
+func (f *fakeServer) PunishPlayer(_ context.Context, playerId, reason string) error {
+	return f.add(command{Name: "punish", PlayerId: playerId, Message: reason})
+}
+
+func (f *fakeServer) Kick(_ context.Context, playerId, reason string) error {
+	return f.add(command{Name: "kick", PlayerId: playerId, Message: reason})
+}
+
+func (f *fakeServer) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+}
+
+func (f *fakeServer) TempBan(_ context.Context, playerId string, _ int32, reason, _ string) error {
+	return f.add(command{Name: "temp-ban", PlayerId: playerId, Message: reason})
+}
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// unhealthyAfter is the number of consecutive failed polls after which a worker is considered unhealthy.
+const unhealthyAfter = 5
+
+// Health is the state of the connection of a worker to its server.
+type Health struct {
+	Address string `json:"address"`
+	// State is one of starting, running, backoff or stopped.
+	State string `json:"state,omitempty"`
+	// Started is true once the worker fetched the session of the server for the first time.
+	Started bool `json:"started"`
+	// Connected is true when the last poll of the server succeeded.
+	Connected         bool       `json:"connected"`
+	Healthy           bool       `json:"healthy"`
+	Ready             bool       `json:"ready"`
+	LastSessionPoll   *time.Time `json:"last_session_poll,omitempty"`
+	LastPlayerPoll    *time.Time `json:"last_player_poll,omitempty"`
+	ConsecutiveErrors int        `json:"consecutive_errors"`
+	LastError         string     `json:"last_error,omitempty"`
+}
+
+// newHealth returns the health of a server, which does not have a running worker because of err.
+func newHealth(address string, err error) Health {
+	return Health{Address: address, ConsecutiveErrors: 1, LastError: err.Error()}
+}
+
+// polled updates the health of the worker after a poll of the server, last is the time of the last successful poll
+// of its kind.
+func (w *worker) polled(last *timestamp, err error) {
+	if err != nil {
+		msg := err.Error()
+		w.lastError.Store(&msg)
+		if w.errors.Add(1) == unhealthyAfter {
+			select {
+			case w.failing <- struct{}{}:
+			default:
+			}
+		}
+		return
+	}
+	last.Store(w.clock.Now().UnixNano())
+	w.errors.Store(0)
+}
+
+func (w *worker) Health() Health {
+	h := Health{
+		Address:           w.config().Address(),
+		Started:           w.started.Load(),
+		LastSessionPoll:   w.lastSessionPoll.time(),
+		LastPlayerPoll:    w.lastPlayerPoll.time(),
+		ConsecutiveErrors: int(w.errors.Load()),
+	}
+	if msg := w.lastError.Load(); msg != nil {
+		h.LastError = *msg
+	}
+	if s := w.state.Load(); s != nil {
+		h.State = string(*s)
+	}
+	h.Connected = h.Started && h.ConsecutiveErrors == 0
+	h.Ready = h.Connected && h.State == string(stateRunning)
+	h.Healthy = h.ConsecutiveErrors < unhealthyAfter
+	return h
+}
+
+// timestamp is a time, which can be accessed concurrently. It is the zero value until it is stored first.
+type timestamp struct {
+	atomic.Int64
+}
+
+func (t *timestamp) time() *time.Time {
+	n := t.Load()
+	if n == 0 {
+		return nil
+	}
+	v := time.Unix(0, n)
+	return &v
+}
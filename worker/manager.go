@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/floriansw/go-hll-rcon/rconv2"
+	"github.com/floriansw/hll-geofences/data"
+	"github.com/floriansw/hll-geofences/history"
+)
+
+// Manager runs a worker for each server of the config and keeps them in line with the config when it changes.
+type Manager struct {
+	l *slog.Logger
+	r history.Recorder
+	// newClient creates the client of the worker of a server.
+	newClient func(s data.Server) (Client, error)
+
+	mu       sync.Mutex
+	workers  map[string]*managedWorker
+	disabled map[string]bool
+	// failed are the servers, for which no worker could be started
+	failed map[string]error
+}
+
+type managedWorker struct {
+	w        *worker
+	password string
+	cancel   context.CancelFunc
+}
+
+// ErrUnknownServer is returned when a server is not in the config.
+var ErrUnknownServer = errors.New("unknown server")
+
+// NewManager creates a manager, which starts the workers of the servers with the given addresses paused. The workers
+// record their events with r, which might be nil.
+func NewManager(l *slog.Logger, r history.Recorder, disabled ...string) *Manager {
+	m := &Manager{
+		l:        l,
+		r:        r,
+		workers:  map[string]*managedWorker{},
+		disabled: map[string]bool{},
+		failed:   map[string]error{},
+	}
+	m.newClient = m.poolClient
+	for _, address := range disabled {
+		m.disabled[address] = true
+	}
+	return m
+}
+
+// SetEnabled pauses or resumes the worker of the server with the given address, see worker.SetEnabled. The state is
+// kept when the worker is restarted.
+func (m *Manager) SetEnabled(address string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mw, ok := m.workers[address]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownServer, address)
+	}
+	mw.w.SetEnabled(enabled)
+	if enabled {
+		delete(m.disabled, address)
+	} else {
+		m.disabled[address] = true
+	}
+	m.l.Info("set-enabled", "server", address, "enabled", enabled)
+	return nil
+}
+
+// Status returns the status of all workers, sorted by address.
+func (m *Manager) Status() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var v []Status
+	for _, mw := range m.workers {
+		v = append(v, mw.w.Status())
+	}
+	slices.SortFunc(v, func(a, b Status) int {
+		return strings.Compare(a.Address, b.Address)
+	})
+	return v
+}
+
+// Health returns the health of all servers of the config, sorted by address. This includes servers, for which no worker
+// could be started.
+func (m *Manager) Health() []Health {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var v []Health
+	for _, mw := range m.workers {
+		v = append(v, mw.w.Health())
+	}
+	for address, err := range m.failed {
+		v = append(v, newHealth(address, err))
+	}
+	slices.SortFunc(v, func(a, b Health) int {
+		return strings.Compare(a.Address, b.Address)
+	})
+	return v
+}
+
+// Apply starts workers for servers that are new, stops the workers of servers that were removed and passes the config
+// to the workers of all other servers. Servers are identified by their data.Server.Address. A worker is restarted when
+// the password of its server changed.
+func (m *Manager) Apply(ctx context.Context, servers []data.Server) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := map[string]bool{}
+	clear(m.failed)
+	for _, s := range servers {
+		address := s.Address()
+		if seen[address] {
+			m.l.Warn("duplicate-server", "server", address)
+			continue
+		}
+		seen[address] = true
+
+		if mw, ok := m.workers[address]; ok {
+			if mw.password == s.Password {
+				mw.w.Update(s)
+				m.l.Info("update-worker", "server", address)
+				continue
+			}
+			m.stop(address)
+		}
+		m.start(ctx, s)
+	}
+	for address := range m.workers {
+		if !seen[address] {
+			m.stop(address)
+		}
+	}
+}
+
+// Stop stops all workers.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for address := range m.workers {
+		m.stop(address)
+	}
+}
+
+func (m *Manager) start(ctx context.Context, s data.Server) {
+	client, err := m.newClient(s)
+	if err != nil {
+		m.l.Error("create-connection-pool", "server", s.Host, "error", err)
+		m.failed[s.Address()] = err
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	w := NewWorker(m.l, client, s, m.r)
+	w.SetEnabled(!m.disabled[s.Address()])
+	m.workers[s.Address()] = &managedWorker{w: w, password: s.Password, cancel: cancel}
+	m.l.Info("start-worker", "server", s.Address())
+	go func() {
+		w.Run(ctx)
+		client.Close()
+	}()
+}
+
+// poolClient creates a Client with a new connection pool for the server s.
+func (m *Manager) poolClient(s data.Server) (Client, error) {
+	pool, err := rconv2.NewConnectionPool(rconv2.ConnectionPoolOptions{
+		Logger:   m.l,
+		Hostname: s.Host,
+		Port:     s.Port,
+		Password: s.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewPoolClient(pool), nil
+}
+
+// stop stops the worker of the server with the given address, the connections of its client are closed once the worker
+// returned.
+func (m *Manager) stop(address string) {
+	m.workers[address].cancel()
+	delete(m.workers, address)
+	m.l.Info("stop-worker", "server", address)
+}
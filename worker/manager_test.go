@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/floriansw/hll-geofences/data"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Manager", func() {
+	var m *Manager
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var mu sync.Mutex
+	// clients are the clients created for each server, by address
+	var clients map[string][]*fakeServer
+	var unreachable error
+
+	created := func(address string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(clients[address])
+	}
+	addresses := func() (v []string) {
+		for _, s := range m.Status() {
+			v = append(v, s.Address)
+		}
+		return
+	}
+	server := func(port int, password string) data.Server {
+		return data.Server{Host: "127.0.0.1", Port: port, Password: password, AlliesFence: []data.Fence{{X: Pointer("F")}}}
+	}
+
+	BeforeEach(func() {
+		clients, unreachable = map[string][]*fakeServer{}, nil
+		m = NewManager(slog.New(slog.NewTextHandler(io.Discard, nil)), nil, "127.0.0.1:7781")
+		m.newClient = func(s data.Server) (Client, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if unreachable != nil {
+				return nil, unreachable
+			}
+			f := &fakeServer{}
+			f.SetMap("CARENTAN", "Warfare")
+			clients[s.Address()] = append(clients[s.Address()], f)
+			return f, nil
+		}
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		m.Stop()
+		cancel()
+	})
+
+	It("starts a worker for each server", func() {
+		m.Apply(ctx, []data.Server{server(7779, "a"), server(7780, "b")})
+		Expect(addresses()).To(Equal([]string{"127.0.0.1:7779", "127.0.0.1:7780"}))
+		Expect(created("127.0.0.1:7779")).To(Equal(1))
+		Expect(created("127.0.0.1:7780")).To(Equal(1))
+		Eventually(func() []Health { return m.Health() }).Should(HaveEach(HaveField("Started", BeTrue())))
+	})
+
+	It("stops the workers of removed servers", func() {
+		m.Apply(ctx, []data.Server{server(7779, "a"), server(7780, "b")})
+		m.Apply(ctx, []data.Server{server(7780, "b")})
+		Expect(addresses()).To(Equal([]string{"127.0.0.1:7780"}))
+		Expect(created("127.0.0.1:7780")).To(Equal(1))
+		Eventually(clients["127.0.0.1:7779"][0].Closed).Should(BeTrue())
+		Expect(clients["127.0.0.1:7780"][0].Closed()).To(BeFalse())
+	})
+
+	It("updates the workers of unchanged servers", func() {
+		m.Apply(ctx, []data.Server{server(7779, "a")})
+		w := m.workers["127.0.0.1:7779"].w
+		changed := server(7779, "a")
+		changed.PunishAfterSeconds = Pointer(30)
+		m.Apply(ctx, []data.Server{changed})
+		Expect(created("127.0.0.1:7779")).To(Equal(1))
+		Expect(m.workers["127.0.0.1:7779"].w).To(BeIdenticalTo(w))
+		Expect(w.config().PunishAfterSeconds).To(Equal(Pointer(30)))
+	})
+
+	It("restarts the worker when the password changed", func() {
+		m.Apply(ctx, []data.Server{server(7779, "a")})
+		w := m.workers["127.0.0.1:7779"].w
+		m.Apply(ctx, []data.Server{server(7779, "b")})
+		Expect(created("127.0.0.1:7779")).To(Equal(2))
+		Expect(m.workers["127.0.0.1:7779"].w).ToNot(BeIdenticalTo(w))
+		Expect(m.workers["127.0.0.1:7779"].password).To(Equal("b"))
+		Eventually(clients["127.0.0.1:7779"][0].Closed).Should(BeTrue())
+	})
+
+	It("keeps servers paused across restarts", func() {
+		m.Apply(ctx, []data.Server{server(7779, "a"), server(7781, "a")})
+		Expect(m.SetEnabled("127.0.0.1:7779", false)).To(Succeed())
+		m.Apply(ctx, []data.Server{server(7779, "b"), server(7781, "b")})
+		Expect(m.Status()).To(HaveEach(HaveField("Enabled", BeFalse())))
+	})
+
+	It("reports servers, for which no client could be created", func() {
+		unreachable = errors.New("invalid host")
+		m.Apply(ctx, []data.Server{server(7779, "a")})
+		Expect(m.Status()).To(BeEmpty())
+		Expect(m.Health()).To(ConsistOf(And(HaveField("Address", "127.0.0.1:7779"), HaveField("LastError", "invalid host"))))
+	})
+})
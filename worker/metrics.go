@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"github.com/floriansw/hll-geofences/metrics"
+)
+
+var (
+	warningsSent = metrics.NewCounter(metrics.Default, "hll_geofences_warnings_sent_total",
+		"Number of warnings sent to players outside of the fences.", "server")
+	punishments = metrics.NewCounter(metrics.Default, "hll_geofences_punishments_total",
+		"Number of players punished for being outside of the fences.", "server")
+	kicks = metrics.NewCounter(metrics.Default, "hll_geofences_kicks_total",
+		"Number of players kicked for being outside of the fences repeatedly.", "server")
+	tempBans = metrics.NewCounter(metrics.Default, "hll_geofences_temp_bans_total",
+		"Number of players temporarily banned for being outside of the fences repeatedly.", "server")
+	playersOutside = metrics.NewGauge(metrics.Default, "hll_geofences_players_outside",
+		"Number of players currently outside of the fences.", "server")
+	activeFences = metrics.NewGauge(metrics.Default, "hll_geofences_active_fences",
+		"Number of fences applying to the current game state.", "server", "team")
+	playerCount = metrics.NewGauge(metrics.Default, "hll_geofences_player_count",
+		"Number of players on the server.", "server")
+	currentMap = metrics.NewGauge(metrics.Default, "hll_geofences_current_map",
+		"The map currently played on the server, always 1.", "server", "map_name", "game_mode")
+	sessionPollDuration = metrics.NewHistogram(metrics.Default, "hll_geofences_session_poll_duration_seconds",
+		"Latency of polling the session of the server.", metrics.DefaultBuckets, "server")
+	playerPollDuration = metrics.NewHistogram(metrics.Default, "hll_geofences_player_poll_duration_seconds",
+		"Latency of polling the players of the server.", metrics.DefaultBuckets, "server")
+	queuedActions = metrics.NewGauge(metrics.Default, "hll_geofences_queued_actions",
+		"Number of warnings and punishments waiting to be sent or being sent.", "server")
+	rconErrors = metrics.NewCounter(metrics.Default, "hll_geofences_rcon_errors_total",
+		"Number of failed RCON commands.", "server", "operation")
+)
+
+// forgetMetrics removes the gauges of the server, which would otherwise be reported with their last value after the
+// worker of the server stopped.
+func forgetMetrics(address string) {
+	for _, g := range []*metrics.Gauge{playersOutside, activeFences, playerCount, currentMap, queuedActions} {
+		g.DeleteLabel("server", address)
+	}
+}
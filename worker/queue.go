@@ -0,0 +1,105 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// action is a command against a player, e.g., a warning or a punishment, which is sent by the senders of an
+// actionQueue.
+type action struct {
+	player string
+	// punish is true for punishments, which are never replaced by a message to the same player.
+	punish bool
+	run    func(ctx context.Context)
+}
+
+// actionQueue is a bounded queue of actions, which are sent by a fixed number of senders, so that the commands of a
+// worker cannot use up the connections of the pool. It holds at most one action per player: a new action replaces the
+// queued one of the same player, unless the queued one is a punishment. The actions of a player are never sent at the
+// same time: an action added while another one of the player is sent, is sent by the same sender afterwards. Adding an
+// action blocks while the queue is full.
+type actionQueue struct {
+	mu      sync.Mutex
+	pending map[string]action
+	// sending are the players, whose action is being sent
+	sending map[string]bool
+	// players are the players with a pending action, in the order the actions were added
+	players chan string
+}
+
+func newActionQueue(size int) *actionQueue {
+	return &actionQueue{pending: map[string]action{}, sending: map[string]bool{}, players: make(chan string, size)}
+}
+
+// add queues a. It blocks until there is space in the queue, or until ctx is done, in which case a is dropped.
+func (q *actionQueue) add(ctx context.Context, a action) {
+	q.mu.Lock()
+	if p, ok := q.pending[a.player]; ok {
+		if !p.punish {
+			q.pending[a.player] = a
+		}
+		q.mu.Unlock()
+		return
+	}
+	q.pending[a.player] = a
+	sending := q.sending[a.player]
+	q.mu.Unlock()
+	if sending {
+		// the sender of the running action sends a afterwards
+		return
+	}
+
+	select {
+	case q.players <- a.player:
+	case <-ctx.Done():
+		q.mu.Lock()
+		delete(q.pending, a.player)
+		q.mu.Unlock()
+	}
+}
+
+// send runs the queued actions one after another until ctx is done.
+func (q *actionQueue) send(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case player := <-q.players:
+			q.mu.Lock()
+			// without a pending action, the action was removed with clear, and while the player is being sent to,
+			// the pending action is sent by the sender of the running one
+			a, ok := q.pending[player]
+			ok = ok && !q.sending[player]
+			if ok {
+				delete(q.pending, player)
+				q.sending[player] = true
+			}
+			q.mu.Unlock()
+			for ok {
+				a.run(ctx)
+				q.mu.Lock()
+				if a, ok = q.pending[player]; ok {
+					delete(q.pending, player)
+				} else {
+					delete(q.sending, player)
+				}
+				q.mu.Unlock()
+			}
+		}
+	}
+}
+
+// clear drops all actions, which were not started yet.
+func (q *actionQueue) clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	clear(q.pending)
+}
+
+// len returns the number of actions, which are queued or being sent.
+func (q *actionQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending) + len(q.sending)
+}
@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("actionQueue", func() {
+	var q *actionQueue
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var mu sync.Mutex
+	var sent []string
+
+	// record returns an action of the player, which records name when it is sent.
+	record := func(player, name string, punish bool) action {
+		return action{player: player, punish: punish, run: func(context.Context) {
+			mu.Lock()
+			defer mu.Unlock()
+			sent = append(sent, name)
+		}}
+	}
+	getSent := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return sent
+	}
+
+	BeforeEach(func() {
+		q = newActionQueue(2)
+		ctx, cancel = context.WithCancel(context.Background())
+		sent = nil
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("sends the latest action of each player", func() {
+		q.add(ctx, record("1", "first warning", false))
+		q.add(ctx, record("1", "second warning", false))
+		q.add(ctx, record("2", "warning", false))
+		Expect(q.len()).To(Equal(2))
+
+		go q.send(ctx)
+		Eventually(getSent).Should(Equal([]string{"second warning", "warning"}))
+		Eventually(q.len).Should(BeZero())
+	})
+
+	It("does not replace a punishment", func() {
+		q.add(ctx, record("1", "punish", true))
+		q.add(ctx, record("1", "warning", false))
+
+		go q.send(ctx)
+		Eventually(getSent).Should(Equal([]string{"punish"}))
+	})
+
+	It("blocks while the queue is full", func() {
+		q.add(ctx, record("1", "1", false))
+		q.add(ctx, record("2", "2", false))
+		added := make(chan struct{})
+		go func() {
+			defer close(added)
+			q.add(ctx, record("3", "3", false))
+		}()
+		Consistently(added, 50*time.Millisecond).ShouldNot(BeClosed())
+
+		go q.send(ctx)
+		Eventually(added).Should(BeClosed())
+		Eventually(getSent).Should(Equal([]string{"1", "2", "3"}))
+	})
+
+	It("does not send two actions of a player at the same time", func() {
+		started, release := make(chan struct{}), make(chan struct{})
+		q.add(ctx, action{player: "1", punish: true, run: func(context.Context) {
+			close(started)
+			<-release
+			record("1", "punish", true).run(ctx)
+		}})
+		go q.send(ctx)
+		go q.send(ctx)
+		Eventually(started).Should(BeClosed())
+		q.add(ctx, record("1", "warning", false))
+		q.add(ctx, record("2", "warning 2", false))
+		Eventually(getSent).Should(Equal([]string{"warning 2"}))
+		Consistently(getSent, 50*time.Millisecond).Should(Equal([]string{"warning 2"}))
+
+		close(release)
+		Eventually(getSent).Should(Equal([]string{"warning 2", "punish", "warning"}))
+		Eventually(q.len).Should(BeZero())
+	})
+
+	It("drops the actions not started yet when cleared", func() {
+		q.add(ctx, record("1", "1", false))
+		q.clear()
+		q.add(ctx, record("2", "2", false))
+
+		go q.send(ctx)
+		Eventually(getSent).Should(Equal([]string{"2"}))
+		Expect(q.len()).To(BeZero())
+	})
+})
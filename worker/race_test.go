@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/floriansw/go-hll-rcon/rconv2/api"
+	"github.com/floriansw/hll-geofences/data"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Worker with many players", func() {
+	It("runs a game of 100 moving players without data races", func() {
+		server := &fakeServer{}
+		server.SetMap("CARENTAN", "Warfare")
+		clock := newFakeClock()
+		config := data.Server{
+			Host:        "127.0.0.1",
+			Port:        7779,
+			AxisFence:   []data.Fence{{X: Pointer("E")}},
+			AlliesFence: []data.Fence{{X: Pointer("F")}},
+			Messages:    &data.Messages{BackInside: Pointer("back inside")},
+		}
+		w := NewWorker(slog.New(slog.NewTextHandler(io.Discard, nil)), server, config, nil)
+		w.clock = clock
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Run(ctx)
+		}()
+		Eventually(func() bool { return w.Health().Ready }).Should(BeTrue())
+
+		// readers and writers of the worker state, which run concurrently to the poll loops
+		for _, f := range []func(i int){
+			func(int) { w.Status() },
+			func(int) { w.Health() },
+			func(int) { w.Update(config) },
+			func(i int) { w.SetEnabled(i%100 != 99) },
+		} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; ctx.Err() == nil; i++ {
+					f(i)
+					time.Sleep(time.Millisecond)
+				}
+			}()
+		}
+
+		r := rand.New(rand.NewSource(1))
+		players := make([]api.GetPlayerResponse, 100)
+		for i := range players {
+			team := api.PlayerTeam(api.PlayerTeamGer)
+			if i%2 == 0 {
+				team = api.PlayerTeamUs
+			}
+			players[i] = api.GetPlayerResponse{Id: fmt.Sprint(i), Name: fmt.Sprintf("player-%d", i), Team: team}
+		}
+		for step := 0; step < 200; step++ {
+			for i := range players {
+				// the columns E and F are at -20000 to 0 and 0 to 20000, so that most players move in and out of them
+				players[i].Position = api.WorldPosition{X: r.Float64()*80000 - 40000, Y: r.Float64()*80000 - 40000, Z: 100}
+			}
+			server.SetPlayers(players...)
+			if step%50 == 0 {
+				server.SetMap([]string{"CARENTAN", "FOY"}[step/50%2], "Warfare")
+			}
+			clock.Advance(500 * time.Millisecond)
+			time.Sleep(time.Millisecond)
+		}
+
+		Eventually(server.Commands).Should(ContainElement(HaveField("Name", "punish")))
+		cancel()
+		wg.Wait()
+	})
+})
@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+type state string
+
+const (
+	stateStarting state = "starting"
+	stateRunning  state = "running"
+	stateBackoff  state = "backoff"
+	stateStopped  state = "stopped"
+)
+
+// Run runs the worker until ctx is done. The poll loops are started once the session of the server could be fetched
+// and are restarted when the polls failed unhealthyAfter times in a row. Both is retried with an exponential backoff
+// with jitter, e.g., while the server is restarting.
+func (w *worker) Run(ctx context.Context) {
+	b := backoff{min: w.retryMin, max: w.retryMax}
+	for {
+		w.setState(stateStarting)
+		started, err := w.run(ctx)
+		if ctx.Err() != nil {
+			w.setState(stateStopped)
+			forgetMetrics(w.config().Address())
+			return
+		}
+		if started {
+			b.reset()
+		}
+		d := b.next()
+		w.setState(stateBackoff, "error", err, "retry_in", d.String(), "attempt", b.attempt)
+		select {
+		case <-ctx.Done():
+			w.setState(stateStopped)
+			forgetMetrics(w.config().Address())
+			return
+		case <-w.clock.After(d):
+		}
+	}
+}
+
+// run fetches the session of the server and runs the poll loops and the senders of the queued actions until ctx is
+// done or the polls are failing. It reports whether the poll loops were started.
+func (w *worker) run(ctx context.Context) (bool, error) {
+	if err := w.populateSession(ctx); err != nil {
+		return false, fmt.Errorf("fetch session: %w", err)
+	}
+	w.started.Store(true)
+	w.setState(stateRunning)
+
+	// a signal of a previous run is outdated
+	select {
+	case <-w.failing:
+	default:
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	for _, loop := range []struct {
+		f        func(context.Context, Ticker)
+		interval time.Duration
+	}{
+		{w.pollSession, w.sessionInterval},
+		{w.pollPlayers, w.playerInterval},
+		{w.punishPlayers, w.punishInterval},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loop.f(ctx, w.clock.NewTicker(loop.interval))
+		}()
+	}
+
+	for range w.senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.actions.send(ctx)
+		}()
+	}
+
+	var err error
+	select {
+	case <-ctx.Done():
+	case <-w.failing:
+		err = fmt.Errorf("%d polls failed in a row", unhealthyAfter)
+	}
+	cancel()
+	wg.Wait()
+	return true, err
+}
+
+// setState changes the state of the worker and logs the transition together with args.
+func (w *worker) setState(s state, args ...any) {
+	old := w.state.Swap(&s)
+	if old != nil && *old == s {
+		return
+	}
+	from := ""
+	if old != nil {
+		from = string(*old)
+	}
+	w.l.Info("worker-state", append([]any{"server", w.config().Address(), "from", from, "to", string(s)}, args...)...)
+}
+
+// backoff is an exponential backoff with jitter between min and max.
+type backoff struct {
+	min, max time.Duration
+	attempt  int
+}
+
+// next returns the duration to wait before the next attempt, a random duration between half of and the full
+// exponential delay of the attempt.
+func (b *backoff) next() time.Duration {
+	d := b.max
+	if b.attempt < 32 {
+		d = min(b.min<<b.attempt, b.max)
+	}
+	b.attempt++
+	return d/2 + rand.N(d/2+1)
+}
+
+func (b *backoff) reset() {
+	b.attempt = 0
+}
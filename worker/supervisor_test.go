@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/floriansw/go-hll-rcon/rconv2/api"
+	"github.com/floriansw/hll-geofences/data"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// flakyClient is a Client, which fails all commands while down is true.
+type flakyClient struct {
+	mu       sync.Mutex
+	down     bool
+	sessions int
+}
+
+var errDown = errors.New("connection refused")
+
+func (f *flakyClient) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *flakyClient) sessionCalls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sessions
+}
+
+func (f *flakyClient) err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errDown
+	}
+	return nil
+}
+
+func (f *flakyClient) SessionInfo(context.Context) (*api.GetSessionResponse, error) {
+	f.mu.Lock()
+	f.sessions++
+	f.mu.Unlock()
+	if err := f.err(); err != nil {
+		return nil, err
+	}
+	return &api.GetSessionResponse{MapName: "CARENTAN", GameMode: "Warfare"}, nil
+}
+
+func (f *flakyClient) Players(context.Context) (*api.GetPlayersResponse, error) {
+	if err := f.err(); err != nil {
+		return nil, err
+	}
+	return &api.GetPlayersResponse{}, nil
+}
+
+func (f *flakyClient) MessagePlayer(context.Context, string, string) error { return f.err() }
+func (f *flakyClient) PunishPlayer(context.Context, string, string) error  { return f.err() }
+func (f *flakyClient) Kick(context.Context, string, string) error          { return f.err() }
+func (f *flakyClient) TempBan(context.Context, string, int32, string, string) error {
+	return f.err()
+}
+func (f *flakyClient) Close() {}
+
+var _ = Describe("Supervisor", func() {
+	var client *flakyClient
+	var w *worker
+	var ctx context.Context
+	var cancel context.CancelFunc
+	var done chan struct{}
+
+	state := func() string {
+		return w.Health().State
+	}
+
+	BeforeEach(func() {
+		client = &flakyClient{}
+		w = NewWorker(slog.New(slog.NewTextHandler(os.Stdout, nil)), client, data.Server{Host: "127.0.0.1", Port: 7779}, nil)
+		w.sessionInterval, w.playerInterval, w.punishInterval = 5*time.Millisecond, 5*time.Millisecond, 5*time.Millisecond
+		w.retryMin, w.retryMax = time.Millisecond, 10*time.Millisecond
+		ctx, cancel = context.WithCancel(context.Background())
+		done = make(chan struct{})
+	})
+
+	JustBeforeEach(func() {
+		go func() {
+			defer close(done)
+			w.Run(ctx)
+		}()
+	})
+
+	AfterEach(func() {
+		cancel()
+		Eventually(done).Should(BeClosed())
+	})
+
+	Context("when the server is down on startup", func() {
+		BeforeEach(func() {
+			client.setDown(true)
+		})
+
+		It("retries until the session can be fetched", func() {
+			Eventually(client.sessionCalls).Should(BeNumerically(">=", 3))
+			Expect(w.Health().Started).To(BeFalse())
+			Expect(w.Health().Ready).To(BeFalse())
+			Expect(w.Health().LastError).To(Equal(errDown.Error()))
+
+			client.setDown(false)
+			Eventually(state).Should(Equal(string(stateRunning)))
+			Expect(w.Health().Started).To(BeTrue())
+			Eventually(func() bool { return w.Health().Ready }).Should(BeTrue())
+		})
+	})
+
+	It("restarts the poll loops after repeated failures", func() {
+		Eventually(state).Should(Equal(string(stateRunning)))
+
+		client.setDown(true)
+		Eventually(state).Should(Equal(string(stateBackoff)))
+		Eventually(func() bool { return w.Health().Healthy }).Should(BeFalse())
+
+		client.setDown(false)
+		Eventually(state).Should(Equal(string(stateRunning)))
+		Eventually(func() bool { return w.Health().Healthy }).Should(BeTrue())
+	})
+
+	It("stops when the context is done", func() {
+		Eventually(state).Should(Equal(string(stateRunning)))
+		cancel()
+		Eventually(done).Should(BeClosed())
+		Expect(state()).To(Equal(string(stateStopped)))
+	})
+})
+
+var _ = Describe("backoff", func() {
+	It("grows exponentially with jitter up to the maximum", func() {
+		b := backoff{min: time.Second, max: 8 * time.Second}
+		for _, d := range []time.Duration{1, 2, 4, 8, 8, 8} {
+			Expect(b.next()).To(And(BeNumerically(">=", d*time.Second/2), BeNumerically("<=", d*time.Second)))
+		}
+		b.reset()
+		Expect(b.next()).To(BeNumerically("<=", time.Second))
+	})
+})
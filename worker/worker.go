@@ -5,77 +5,219 @@ import (
 	"fmt"
 	"log/slog"
 	"slices"
+	"sync/atomic"
 	"time"
 
-	"github.com/floriansw/go-hll-rcon/rconv2"
 	"github.com/floriansw/go-hll-rcon/rconv2/api"
 	"github.com/floriansw/hll-geofences/data"
+	"github.com/floriansw/hll-geofences/history"
 	"github.com/floriansw/hll-geofences/sync"
 )
 
 type worker struct {
-	pool               *rconv2.ConnectionPool
-	l                  *slog.Logger
-	c                  data.Server
-	axisFences         []data.Fence
-	alliesFences       []data.Fence
-	punishAfterSeconds time.Duration
-
-	sessionTicker *time.Ticker
-	playerTicker  *time.Ticker
-	punishTicker  *time.Ticker
-
-	current        *api.GetSessionResponse
+	client  Client
+	clock   Clock
+	l       *slog.Logger
+	r       history.Recorder
+	c       atomic.Pointer[data.Server]
+	enabled atomic.Bool
+
+	sessionInterval time.Duration
+	playerInterval  time.Duration
+	punishInterval  time.Duration
+	// retryMin and retryMax are the bounds of the backoff between attempts to start the worker.
+	retryMin time.Duration
+	retryMax time.Duration
+	// senders is the number of actions of the queue, which are sent concurrently.
+	senders int
+	actions *actionQueue
+
+	// started is true once the session was fetched for the first time
+	started         atomic.Bool
+	state           atomic.Pointer[state]
+	errors          atomic.Int32
+	lastError       atomic.Pointer[string]
+	lastSessionPoll timestamp
+	lastPlayerPoll  timestamp
+	// failing is signalled when the polls failed unhealthyAfter times in a row
+	failing chan struct{}
+
+	// current is replaced by each session and player poll, it is read by the concurrent player checks without locking
+	current        atomic.Pointer[snapshot]
 	outsidePlayers sync.Map[string, outsidePlayer]
 	firstCoord     sync.Map[string, *api.WorldPosition]
+	// lastFence is the allowed fence a player was inside of the last time the player was checked.
+	lastFence sync.Map[string, *data.Fence]
+	// violations are the times a player was punished for being outside of the fences. Unlike the other maps, they
+	// are kept across map changes, so that the Escalation of the server applies to repeat offenders.
+	violations sync.Map[string, []time.Time]
+	// unresolved are the teams of the players, whose team could not be resolved to a faction, so that each player is
+	// logged once only.
+	unresolved sync.Map[string, api.PlayerTeam]
+}
+
+// snapshot is the game state of the last session and player poll, together with the fences applicable to it. A
+// snapshot is never modified after it was stored in the worker.
+type snapshot struct {
+	state data.GameState
+	// factions are the side and the applicable fences of the players of each faction.
+	factions map[data.Faction]fenceSet
+	// samples are the player counts of the latest session polls, the oldest first, which the conditions of the
+	// fences are evaluated with the average of.
+	samples []int
+}
+
+type fenceSet struct {
+	side data.Side
+	// list identifies the configured fences, which the fences were selected from, by their first fence.
+	list   *data.Fence
+	fences []data.Fence
+	// ids are the indexes of the fences in the expanded configured fences, see data.Fence.Expand. They are only set
+	// with a hysteresis.
+	ids []int
+	// since is the time the fences started to apply.
+	since time.Time
+}
+
+// hasFences reports whether any of the factions has applicable fences.
+func (s *snapshot) hasFences() bool {
+	for _, f := range s.factions {
+		if len(f.fences) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// fenceCount returns the number of fences applying to the players of side. Factions of the side, which have the same
+// configured fences, count them once.
+func (s *snapshot) fenceCount(side data.Side) int {
+	n := 0
+	var counted []*data.Fence
+	for _, f := range s.factions {
+		if f.side != side || slices.Contains(counted, f.list) {
+			continue
+		}
+		counted = append(counted, f.list)
+		n += len(f.fences)
+	}
+	return n
 }
 
 type outsidePlayer struct {
 	Name         string
+	Side         data.Side
 	LastGrid     api.Grid
+	LastPosition api.WorldPosition
 	FirstOutside time.Time
+	// Fence is the fence the player left, or the Deny fence the player entered. Its settings apply to the player.
+	Fence *data.Fence
+	// Punished is true once an action against the player was started, so that each violation counts once only.
+	Punished bool
+	// Warned is the number of warnings of the warning schedule, which are due for the player already.
+	Warned int
+	// Returned is true when the player went back into the fences and still needs to be told so.
+	Returned bool
 }
 
-var alliedTeams = []api.PlayerTeam{
-	api.PlayerTeamB8a,
-	api.PlayerTeamDak,
-	api.PlayerTeamGb,
-	api.PlayerTeamRus,
-	api.PlayerTeamUs,
+// factions are the factions of the teams reported by the game. The side of a faction is resolved with the config of
+// the server.
+var factions = map[api.PlayerTeam]data.Faction{
+	api.PlayerTeamGer: data.FactionGER,
+	api.PlayerTeamUs:  data.FactionUS,
+	api.PlayerTeamRus: data.FactionRUS,
+	api.PlayerTeamGb:  data.FactionGB,
+	api.PlayerTeamDak: data.FactionDAK,
+	api.PlayerTeamB8a: data.FactionB8A,
 }
 
-var axisTeams = []api.PlayerTeam{
-	api.PlayerTeamGer,
-}
+// NewWorker creates a worker for the server c. Warnings, punishments and players returning into the fences are
+// recorded with r, if it is not nil.
+func NewWorker(l *slog.Logger, client Client, c data.Server, r history.Recorder) *worker {
+	w := &worker{
+		l:      l,
+		client: client,
+		clock:  realClock{},
+		r:      r,
 
-func NewWorker(l *slog.Logger, pool *rconv2.ConnectionPool, c data.Server) *worker {
-	punishAfterSeconds := 10
-	if c.PunishAfterSeconds != nil {
-		punishAfterSeconds = *c.PunishAfterSeconds
+		sessionInterval: 2 * time.Second,
+		playerInterval:  2000 * time.Millisecond,
+		punishInterval:  time.Second,
+		retryMin:        time.Second,
+		retryMax:        time.Minute,
+		failing:         make(chan struct{}, 1),
+		senders:         2,
+		actions:         newActionQueue(64),
+		outsidePlayers:  sync.Map[string, outsidePlayer]{},
+		firstCoord:      sync.Map[string, *api.WorldPosition]{},
+		lastFence:       sync.Map[string, *data.Fence]{},
+		violations:      sync.Map[string, []time.Time]{},
 	}
-	return &worker{
-		l:                  l,
-		pool:               pool,
-		punishAfterSeconds: time.Duration(punishAfterSeconds) * time.Second,
-		c:                  c,
+	w.c.Store(&c)
+	w.enabled.Store(true)
+	return w
+}
 
-		sessionTicker:  time.NewTicker(2 * time.Second),
-		playerTicker:   time.NewTicker(2000 * time.Millisecond),
-		punishTicker:   time.NewTicker(time.Second),
-		outsidePlayers: sync.Map[string, outsidePlayer]{},
-		firstCoord:     sync.Map[string, *api.WorldPosition]{},
+// SetEnabled pauses or resumes the enforcement of the fences. A paused worker keeps polling the server, but does
+// neither warn nor punish players. Players outside of the fences are forgotten when pausing.
+func (w *worker) SetEnabled(enabled bool) {
+	if w.enabled.Swap(enabled) && !enabled {
+		w.outsidePlayers.Range(func(id string, _ outsidePlayer) bool {
+			w.outsidePlayers.Delete(id)
+			return true
+		})
+		w.actions.clear()
 	}
 }
 
-func (w *worker) Run(ctx context.Context) {
-	if err := w.populateSession(ctx); err != nil {
-		w.l.Error("fetch-session", "error", err)
-		return
+// Status is a snapshot of the state of a worker.
+type Status struct {
+	Address        string `json:"address"`
+	Enabled        bool   `json:"enabled"`
+	MapName        string `json:"map_name,omitempty"`
+	GameMode       string `json:"game_mode,omitempty"`
+	PlayerCount    int    `json:"player_count"`
+	AxisFences     int    `json:"axis_fences"`
+	AlliesFences   int    `json:"allies_fences"`
+	PlayersOutside int    `json:"players_outside"`
+}
+
+func (w *worker) Status() Status {
+	c := w.snapshot()
+	s := Status{
+		Address:      w.config().Address(),
+		Enabled:      w.enabled.Load(),
+		AxisFences:   c.fenceCount(data.SideAxis),
+		AlliesFences: c.fenceCount(data.SideAllies),
+	}
+	if si := c.state.Session; si != nil {
+		s.MapName, s.GameMode, s.PlayerCount = si.MapName, si.GameMode, si.PlayerCount
 	}
+	w.outsidePlayers.Range(func(_ string, o outsidePlayer) bool {
+		if !o.Returned {
+			s.PlayersOutside++
+		}
+		return true
+	})
+	return s
+}
+
+// Update replaces the config of the worker. Fences and messages of the new config are used at once, the applicable
+// fences are re-evaluated with the next poll.
+func (w *worker) Update(c data.Server) {
+	w.c.Store(&c)
+}
+
+func (w *worker) config() data.Server {
+	return *w.c.Load()
+}
 
-	go w.pollSession(ctx)
-	go w.pollPlayers(ctx)
-	go w.punishPlayers(ctx)
+// snapshot returns the game state of the last successful polls, which is empty before the first one.
+func (w *worker) snapshot() *snapshot {
+	if c := w.current.Load(); c != nil {
+		return c
+	}
+	return &snapshot{}
 }
 
 func (w *worker) clearSyncMaps() {
@@ -87,88 +229,363 @@ func (w *worker) clearSyncMaps() {
 		w.firstCoord.Delete(id)
 		return true
 	})
+	w.lastFence.Range(func(id string, _ *data.Fence) bool {
+		w.lastFence.Delete(id)
+		return true
+	})
 }
 
 func (w *worker) populateSession(ctx context.Context) error {
-	return w.pool.WithConnection(ctx, func(c *rconv2.Connection) error {
-		si, err := c.SessionInfo(ctx)
-		if err != nil {
-			return err
-		}
-		if w.current != nil && w.current.MapName != si.MapName {
-			w.l.Info("map-changed", "old_map", w.current.MapName, "new_map", si.MapName)
-			w.clearSyncMaps()
-		}
-		w.current = si
-		w.axisFences = w.applicableFences(w.c.AxisFence)
-		w.alliesFences = w.applicableFences(w.c.AlliesFence)
-		return nil
+	address := w.config().Address()
+	start := w.clock.Now()
+	si, err := w.client.SessionInfo(ctx)
+	w.polled(&w.lastSessionPoll, err)
+	if err != nil {
+		rconErrors.Inc(address, "session-info")
+		return err
+	}
+	old, c := w.updateSnapshot(func(s data.GameState) data.GameState {
+		s.Session = si
+		return s
 	})
+	if old != nil && old.state.Session != nil && old.state.Session.MapName != si.MapName {
+		w.l.Info("map-changed", "old_map", old.state.Session.MapName, "new_map", si.MapName)
+		w.clearSyncMaps()
+	}
+	if old == nil || !sameMatch(old.state.Session, si) {
+		w.logAttacker(si)
+	}
+	sessionPollDuration.Observe(w.since(start).Seconds(), address)
+	currentMap.DeleteLabel("server", address)
+	currentMap.Set(1, address, si.MapName, si.GameMode)
+	playerCount.Set(float64(si.PlayerCount), address)
+	activeFences.Set(float64(c.fenceCount(data.SideAxis)), address, string(data.SideAxis))
+	activeFences.Set(float64(c.fenceCount(data.SideAllies)), address, string(data.SideAllies))
+	return nil
+}
+
+// logAttacker logs the side attacking, when an Offensive is played on the map of the session si. The side is a warning,
+// when it is assumed, because OffensiveAttackers of the server does not configure the map.
+func (w *worker) logAttacker(si *api.GetSessionResponse) {
+	c := w.config()
+	side, ok := c.Attacker(si)
+	if !ok {
+		return
+	}
+	if _, configured := c.OffensiveAttackers[si.MapName]; configured {
+		w.l.Info("offensive-attacker", "map", si.MapName, "attacker", side)
+	} else {
+		w.l.Warn("offensive-attacker-assumed", "map", si.MapName, "attacker", side)
+	}
 }
 
-func (w *worker) punishPlayers(ctx context.Context) {
+// updateSnapshot replaces the snapshot with the one of the game state returned by f, which is called with the game
+// state of the current snapshot, at the current time. It returns the replaced and the new snapshot.
+func (w *worker) updateSnapshot(f func(data.GameState) data.GameState) (*snapshot, *snapshot) {
+	for {
+		old := w.current.Load()
+		var s data.GameState
+		if old != nil {
+			s = old.state
+		}
+		s = f(s)
+		s.Time = w.clock.Now().In(w.config().Location())
+		c := w.evaluate(s, old)
+		if w.current.CompareAndSwap(old, c) {
+			return old, c
+		}
+	}
+}
+
+// evaluate returns a snapshot of the game state s with the fences of the server, which apply to it. The fences are
+// selected with the Hysteresis of the server against the ones of the previous snapshot prev, which may be nil.
+func (w *worker) evaluate(s data.GameState, prev *snapshot) *snapshot {
+	config := w.config()
+	c := &snapshot{state: s, factions: map[data.Faction]fenceSet{}}
+	// without a hysteresis, the fences are selected with the game state alone
+	var held map[data.Faction]fenceSet
+	if config.Hysteresis != nil {
+		if prev != nil {
+			c.samples = prev.samples
+		}
+		if s.Session != nil && (prev == nil || prev.state.Session != s.Session) {
+			c.samples = append(slices.Clone(c.samples), s.Session.PlayerCount)
+			c.samples = c.samples[max(len(c.samples)-config.Hysteresis.SampleCount(), 0):]
+		}
+		// the conditions see the average of the player counts, while the snapshot keeps the one of the latest poll
+		if len(c.samples) > 1 {
+			si := *s.Session
+			si.PlayerCount = average(c.samples)
+			s.Session = &si
+		}
+		// the fences of another map or game mode are not held
+		if prev != nil && sameMatch(prev.state.Session, s.Session) {
+			held = prev.factions
+		}
+	}
+
+	for _, f := range data.Factions {
+		fences, side, _ := config.Fences(f, s.Session)
+		var before *fenceSet
+		if p, ok := held[f]; ok {
+			before = &p
+		}
+		set := w.selectFences(fences, s, before, config.Hysteresis)
+		set.side = side
+		c.factions[f] = set
+	}
+	return c
+}
+
+// sameMatch reports whether the sessions a and b are on the same map and game mode.
+func sameMatch(a, b *api.GetSessionResponse) bool {
+	return a != nil && b != nil && a.MapName == b.MapName && a.GameMode == b.GameMode
+}
+
+// average returns the rounded average of v, which must not be empty.
+func average(v []int) int {
+	sum := 0
+	for _, n := range v {
+		sum += n
+	}
+	return (sum + len(v)/2) / len(v)
+}
+
+func (w *worker) punishPlayers(ctx context.Context, t Ticker) {
 	for {
 		select {
 		case <-ctx.Done():
-			w.punishTicker.Stop()
+			t.Stop()
 			return
-		case <-w.punishTicker.C:
-			w.outsidePlayers.Range(func(id string, o outsidePlayer) bool {
-				if time.Since(o.FirstOutside) > w.punishAfterSeconds && time.Since(o.FirstOutside) < w.punishAfterSeconds+5*time.Second {
-					go w.punishPlayer(ctx, id, o)
+		case <-t.C():
+			if w.enabled.Load() {
+				w.punishTick(ctx)
+			}
+		}
+	}
+}
+
+// punishTick queues the warnings, which are due, for the players outside of the fences and the punishments of the
+// players, whose grace period is over. It blocks while the queue of actions is full.
+func (w *worker) punishTick(ctx context.Context) {
+	c := w.config()
+	outsideCount := 0
+	w.outsidePlayers.Range(func(id string, _ outsidePlayer) bool {
+		// the player is decided upon with the latest state, as the player might have been checked concurrently
+		var o outsidePlayer
+		var returned, warn, punish bool
+		var warning int
+		w.outsidePlayers.Update(id, func(v outsidePlayer, ok bool) (outsidePlayer, bool) {
+			if !ok {
+				return v, false
+			}
+			o = v
+			if v.Returned {
+				returned = true
+				return v, false
+			}
+			s := c.For(v.Side, v.Fence)
+			outside := w.since(v.FirstOutside)
+			if due := dueWarnings(s.WarningSchedule(), outside); !v.Punished && due > v.Warned {
+				// only the latest warning is sent, when more than one became due since the last tick
+				v.Warned, warn, warning = due, true, due-1
+			}
+			if !v.Punished && outside > s.PunishAfter() && outside < s.PunishAfter()+5*time.Second {
+				v.Punished, punish = true, true
+			}
+			o = v
+			return v, true
+		})
+		s := c.For(o.Side, o.Fence)
+		if returned {
+			w.actions.add(ctx, action{player: id, run: func(ctx context.Context) {
+				w.messagePlayer(ctx, "message-player-back-inside", o, s.BackInsideMessage())
+			}})
+			return true
+		}
+		outsideCount++
+		if warn {
+			remaining := max(s.PunishAfter()-w.since(o.FirstOutside), 0).Round(time.Second)
+			message := fmt.Sprintf(*s.WarningSchedule()[warning].Message, remaining.String())
+			w.actions.add(ctx, action{player: id, run: func(ctx context.Context) {
+				if w.messagePlayer(ctx, "message-player-outside-fence", o, message) {
+					warningsSent.Inc(c.Address())
+					w.record(history.EventWarning, id, o)
 				}
-				return true
-			})
+			}})
 		}
+		if punish {
+			w.actions.add(ctx, action{player: id, punish: true, run: func(ctx context.Context) {
+				w.punishPlayer(ctx, id, o)
+			}})
+		}
+		return true
+	})
+	playersOutside.Set(float64(outsideCount), c.Address())
+	queuedActions.Set(float64(w.actions.len()), c.Address())
+}
+
+func (w *worker) since(t time.Time) time.Duration {
+	return w.clock.Now().Sub(t)
+}
+
+// dueWarnings returns the number of warnings, which are due for a player being outside of the fences for outside.
+func dueWarnings(warnings []data.Warning, outside time.Duration) (v int) {
+	for _, warning := range warnings {
+		if time.Duration(warning.AfterSeconds)*time.Second <= outside {
+			v++
+		}
+	}
+	return
+}
+
+// messagePlayer sends message to the player and reports whether it was sent.
+func (w *worker) messagePlayer(ctx context.Context, event string, o outsidePlayer, message string) bool {
+	if err := w.client.MessagePlayer(ctx, o.Name, message); err != nil {
+		rconErrors.Inc(w.config().Address(), "message-player")
+		w.l.Error(event, "player", o.Name, "grid", o.LastGrid, "error", err)
+		return false
+	}
+	return true
+}
+
+// record adds an event of the player with the given ID to the history.
+func (w *worker) record(t history.EventType, id string, o outsidePlayer) {
+	if w.r == nil {
+		return
+	}
+	e := history.Event{
+		Time:       w.clock.Now(),
+		Type:       t,
+		Server:     w.config().Address(),
+		PlayerID:   id,
+		PlayerName: o.Name,
+		Team:       string(o.Side),
+		Grid:       o.LastGrid.String(),
+		Position:   history.Position{X: o.LastPosition.X, Y: o.LastPosition.Y, Z: o.LastPosition.Z},
+	}
+	if si := w.snapshot().state.Session; si != nil {
+		e.MapName, e.GameMode = si.MapName, si.GameMode
+	}
+	if err := w.r.Record(e); err != nil {
+		w.l.Error("record-history", "player", o.Name, "event", t, "error", err)
 	}
 }
 
 func (w *worker) punishPlayer(ctx context.Context, id string, o outsidePlayer) {
-	err := w.pool.WithConnection(ctx, func(c *rconv2.Connection) error {
-		return c.PunishPlayer(ctx, id, fmt.Sprintf(w.c.PunishMessage(), w.punishAfterSeconds.String()))
-	})
+	s := w.config().For(o.Side, o.Fence)
+	window := s.Escalation.Window()
+	v, _ := w.violations.Load(id)
+	count := len(recentViolations(v, w.clock.Now(), window)) + 1
+	step := s.Escalation.Step(count)
+	var err error
+	switch step.Action {
+	case data.ActionKick:
+		err = w.client.Kick(ctx, id, step.ReasonOr("Repeatedly left the play area"))
+	case data.ActionTempBan:
+		err = w.client.TempBan(ctx, id, int32(step.Hours), step.ReasonOr("Repeatedly left the play area"), "hll-geofences")
+	default:
+		err = w.client.PunishPlayer(ctx, id, step.ReasonOr(fmt.Sprintf(s.PunishMessage(), s.PunishAfter().String())))
+	}
 	if err != nil {
-		w.l.Error("punish-player", "player_id", id, "error", err)
+		rconErrors.Inc(s.Address(), string(step.Action))
+		w.l.Error("punish-player", "player_id", id, "action", step.String(), "error", err)
+		w.outsidePlayers.Update(id, func(o outsidePlayer, ok bool) (outsidePlayer, bool) {
+			o.Punished = false
+			return o, ok
+		})
 		return
 	}
-	w.l.Info("punish-player", "player", o.Name, "grid", o.LastGrid.String())
+	w.recordViolation(id, window)
+	switch step.Action {
+	case data.ActionKick:
+		kicks.Inc(s.Address())
+	case data.ActionTempBan:
+		tempBans.Inc(s.Address())
+	default:
+		punishments.Inc(s.Address())
+	}
+	w.record(history.EventType(step.Action), id, o)
+	w.l.Info("punish-player", "player", o.Name, "grid", o.LastGrid.String(), "action", step.String(), "violations", count)
+
+	// the player is forgotten a while after the punishment, without keeping the sender of the action busy
+	after := w.clock.After(5 * time.Second)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-after:
+			w.outsidePlayers.Delete(id)
+		}
+	}()
+}
+
+// recordViolation adds a violation of the player and forgets the ones of the player outside of window.
+func (w *worker) recordViolation(id string, window time.Duration) {
+	now := w.clock.Now()
+	w.violations.Update(id, func(v []time.Time, _ bool) ([]time.Time, bool) {
+		return append(recentViolations(v, now, window), now), true
+	})
+}
 
-	time.Sleep(5 * time.Second)
-	w.outsidePlayers.Delete(id)
+// forgetViolations removes the violations, which are outside of the rolling window, of all players.
+func (w *worker) forgetViolations(window time.Duration) {
+	now := w.clock.Now()
+	w.violations.Range(func(id string, _ []time.Time) bool {
+		w.violations.Update(id, func(v []time.Time, _ bool) ([]time.Time, bool) {
+			v = recentViolations(v, now, window)
+			return v, len(v) != 0
+		})
+		return true
+	})
 }
 
-func (w *worker) pollSession(ctx context.Context) {
+func recentViolations(v []time.Time, now time.Time, window time.Duration) []time.Time {
+	return slices.DeleteFunc(slices.Clone(v), func(t time.Time) bool {
+		return now.Sub(t) >= window
+	})
+}
+
+func (w *worker) pollSession(ctx context.Context, t Ticker) {
 	for {
 		select {
 		case <-ctx.Done():
-			w.sessionTicker.Stop()
+			t.Stop()
 			return
-		case <-w.sessionTicker.C:
+		case <-t.C():
 			if err := w.populateSession(ctx); err != nil {
 				w.l.Error("poll-session", "error", err)
 			}
+			w.forgetViolations(w.config().Escalation.Window())
 		}
 	}
 }
 
-func (w *worker) pollPlayers(ctx context.Context) {
+func (w *worker) pollPlayers(ctx context.Context, t Ticker) {
 	for {
 		select {
 		case <-ctx.Done():
-			w.playerTicker.Stop()
+			t.Stop()
 			return
-		case <-w.playerTicker.C:
-			if len(w.alliesFences) == 0 && len(w.axisFences) == 0 {
+		case <-t.C():
+			if !w.enabled.Load() {
 				continue
 			}
 
-			err := w.pool.WithConnection(ctx, func(c *rconv2.Connection) error {
-				players, err := c.Players(ctx)
-				if err != nil {
-					return err
-				}
-				for _, player := range players.Players {
-					go w.checkPlayer(ctx, player)
+			address := w.config().Address()
+			start := w.clock.Now()
+			players, err := w.client.Players(ctx)
+			if err == nil {
+				playerPollDuration.Observe(w.since(start).Seconds(), address)
+				// the fences are re-evaluated with the players of each side, before the players are checked against them
+				axis, allies := w.sides(players.Players)
+				_, c := w.updateSnapshot(func(s data.GameState) data.GameState {
+					s.AxisPlayers, s.AlliesPlayers = axis, allies
+					return s
+				})
+				if c.hasFences() {
+					for _, player := range players.Players {
+						w.checkPlayer(ctx, player)
+					}
 				}
 				w.firstCoord.Range(func(id string, p *api.WorldPosition) bool {
 					for _, player := range players.Players {
@@ -177,17 +594,34 @@ func (w *worker) pollPlayers(ctx context.Context) {
 						}
 					}
 					w.firstCoord.Delete(id)
+					w.lastFence.Delete(id)
+					w.unresolved.Delete(id)
 					return true
 				})
-				return nil
-			})
+			}
+			w.polled(&w.lastPlayerPoll, err)
 			if err != nil {
+				rconErrors.Inc(address, "players")
 				w.l.Error("poll-players", "error", err)
 			}
 		}
 	}
 }
 
+// sides returns the number of players on each side.
+func (w *worker) sides(players []api.GetPlayerResponse) (axis, allies int) {
+	c := w.config()
+	for _, p := range players {
+		switch side, _ := c.Side(factions[p.Team]); side {
+		case data.SideAxis:
+			axis++
+		case data.SideAllies:
+			allies++
+		}
+	}
+	return
+}
+
 func (w *worker) checkPlayer(ctx context.Context, p api.GetPlayerResponse) {
 	if !p.Position.IsSpawned() {
 		w.firstCoord.Store(p.Id, nil)
@@ -197,55 +631,127 @@ func (w *worker) checkPlayer(ctx context.Context, p api.GetPlayerResponse) {
 	// If this is the first time we've seen this player, or if it is still the same position (spawn screen e.g.)
 	// ignore them. The game engine returns the position of a random HQ for players first joining the server, which
 	// might trigger an out-of-fence warning when we do not ignore that here.
-	if fp, ok := w.firstCoord.Load(p.Id); !ok {
-		w.firstCoord.Store(p.Id, &p.Position)
-		return
-	} else if fp != nil && p.Position.Equal(*fp) {
-		return
-	} else {
+	ignore := false
+	w.firstCoord.Update(p.Id, func(fp *api.WorldPosition, ok bool) (*api.WorldPosition, bool) {
+		switch {
+		case !ok:
+			ignore = true
+			return &p.Position, true
+		case fp != nil && p.Position.Equal(*fp):
+			ignore = true
+			return fp, true
+		}
 		// the player moved (e.g., spawned somewhere), makes sure we start tracking
 		// the position of this player and evaluate them against fences.
-		w.firstCoord.Store(p.Id, nil)
+		return nil, true
+	})
+	if ignore {
+		return
 	}
 
-	var fences []data.Fence
-	if slices.Contains(alliedTeams, p.Team) {
-		fences = w.alliesFences
-	} else if slices.Contains(axisTeams, p.Team) {
-		fences = w.axisFences
+	c := w.snapshot()
+	faction, ok := factions[p.Team]
+	if !ok {
+		logged := false
+		w.unresolved.Update(p.Id, func(team api.PlayerTeam, ok bool) (api.PlayerTeam, bool) {
+			logged = ok && team == p.Team
+			return p.Team, true
+		})
+		if !logged {
+			w.l.Warn("unresolved-team", "player", p.Name, "team", int(p.Team))
+		}
+		return
 	}
+	fences, side := c.factions[faction].fences, c.factions[faction].side
 	if len(fences) == 0 {
 		return
 	}
 
-	g := p.Position.Grid(w.current)
-	for _, f := range fences {
-		if f.Includes(g) {
-			w.outsidePlayers.Delete(p.Id)
-			return
+	g := data.GridOf(c.state.Session, p.Position)
+	violates, fence := data.Evaluate(fences, c.state.Session, p.Position)
+	if !violates {
+		if fence != nil {
+			w.lastFence.Store(p.Id, fence)
+		}
+		var back *outsidePlayer
+		w.outsidePlayers.Update(p.Id, func(o outsidePlayer, ok bool) (outsidePlayer, bool) {
+			if !ok || o.Returned {
+				return o, ok
+			}
+			if !o.Punished {
+				o.LastGrid, o.LastPosition = g, p.Position
+				back = &o
+			}
+			if o.Punished || w.config().For(o.Side, o.Fence).BackInsideMessage() == "" {
+				return o, false
+			}
+			o.Returned = true
+			return o, true
+		})
+		if back != nil {
+			w.record(history.EventBackInside, p.Id, *back)
 		}
-	}
-	if o, ok := w.outsidePlayers.Load(p.Id); ok {
-		o.LastGrid = g
-		w.outsidePlayers.Store(p.Id, o)
 		return
 	}
 
-	w.outsidePlayers.Store(p.Id, outsidePlayer{FirstOutside: time.Now(), Name: p.Name, LastGrid: g})
-	w.l.Info("player-outside-fence", "player", p.Name, "grid", g)
-	err := w.pool.WithConnection(ctx, func(c *rconv2.Connection) error {
-		return c.MessagePlayer(ctx, p.Name, fmt.Sprintf(w.c.WarningMessage(), w.punishAfterSeconds.String()))
+	// the boundary crossed is either the Deny fence the player is inside of, or the fence the player was inside of
+	// before leaving it
+	if fence == nil {
+		fence, _ = w.lastFence.Load(p.Id)
+	}
+	left := false
+	w.outsidePlayers.Update(p.Id, func(o outsidePlayer, ok bool) (outsidePlayer, bool) {
+		if ok && !o.Returned {
+			o.LastGrid, o.LastPosition = g, p.Position
+			return o, true
+		}
+		// the warnings are sent by punishPlayers, starting with its next tick
+		left = true
+		return outsidePlayer{FirstOutside: w.clock.Now(), Name: p.Name, Side: side, LastGrid: g, LastPosition: p.Position, Fence: fence}, true
 	})
-	if err != nil {
-		w.l.Error("message-player-outside-fence", "player", p.Name, "grid", g, "error", err)
+	if left {
+		w.l.Info("player-outside-fence", "player", p.Name, "grid", g)
 	}
 }
 
-func (w *worker) applicableFences(f []data.Fence) (v []data.Fence) {
+// selectFences returns the fences of f, whose conditions match the game state s. Fences with invalid conditions are
+// logged and do not apply. With the hysteresis h, the fences of prev, which applied before, keep applying within the
+// Band of h, and prev is kept as a whole, until it applied for the hold time of h. prev is nil, when no fences applied
+// to the match before. The fences are identified by their index in the expanded fences of f, so that prev is ignored,
+// when it was selected from other fences than f.
+func (w *worker) selectFences(f []data.Fence, s data.GameState, prev *fenceSet, h *data.Hysteresis) fenceSet {
+	v := fenceSet{since: s.Time}
+	if len(f) != 0 {
+		v.list = &f[0]
+	}
+	// the fences are only held against the same configured fences, e.g., not against the ones of a reloaded config
+	if h == nil || prev == nil || prev.list != v.list {
+		prev = nil
+	}
+	i := 0
 	for _, fence := range f {
-		if fence.Matches(w.current) {
-			v = append(v, fence)
+		for _, fence := range fence.Expand() {
+			band := 0
+			if prev != nil {
+				if _, ok := slices.BinarySearch(prev.ids, i); ok {
+					band = h.Width()
+				}
+			}
+			ok, err := fence.MatchesWithin(s, band)
+			if err != nil {
+				w.l.Error("match-fence", "error", err)
+			}
+			if ok {
+				v.fences = append(v.fences, fence)
+				if h != nil {
+					v.ids = append(v.ids, i)
+				}
+			}
+			i++
 		}
 	}
-	return
+	if prev != nil && (slices.Equal(v.ids, prev.ids) || s.Time.Sub(prev.since) < h.Hold()) {
+		return *prev
+	}
+	return v
 }
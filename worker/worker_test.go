@@ -0,0 +1,487 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/floriansw/go-hll-rcon/rconv2/api"
+	"github.com/floriansw/hll-geofences/data"
+	"github.com/floriansw/hll-geofences/history"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeRecorder is a history.Recorder keeping the recorded events in memory.
+type fakeRecorder struct {
+	mu     sync.Mutex
+	events []history.Event
+}
+
+func (f *fakeRecorder) Record(e history.Event) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, e)
+	return nil
+}
+
+func (f *fakeRecorder) Events() []history.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.events)
+}
+
+var _ = Describe("Worker", func() {
+	var server *fakeServer
+	var recorder *fakeRecorder
+	var clock *fakeClock
+	var config data.Server
+	var w *worker
+	var ctx context.Context
+	var cancel context.CancelFunc
+
+	// E5 and F5 numpad 5 on Carentan Warfare, a position with X+Y+Z=0 is not spawned
+	e5 := api.WorldPosition{X: -10080, Y: -10080, Z: 100}
+	f5 := api.WorldPosition{X: 10080, Y: -10080, Z: 100}
+	f5East := api.WorldPosition{X: 10500, Y: -10080, Z: 100}
+
+	player := func(p api.WorldPosition) api.GetPlayerResponse {
+		return api.GetPlayerResponse{Id: "1", Name: "Able", Team: api.PlayerTeamUs, Position: p}
+	}
+
+	BeforeEach(func() {
+		server = &fakeServer{}
+		server.SetMap("CARENTAN", "Warfare")
+		recorder = &fakeRecorder{}
+		clock = newFakeClock()
+		config = data.Server{
+			Host:        "127.0.0.1",
+			Port:        7779,
+			AlliesFence: []data.Fence{{X: Pointer("F")}},
+			Messages:    &data.Messages{Warning: Pointer("warning %s"), Punish: Pointer("punish %s")},
+		}
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	JustBeforeEach(func() {
+		w = NewWorker(slog.New(slog.NewTextHandler(os.Stdout, nil)), server, config, recorder)
+		w.clock = clock
+		go w.actions.send(ctx)
+		Expect(w.populateSession(ctx)).To(Succeed())
+		// the first position of a player is ignored, the player needs to move to be checked
+		w.checkPlayer(ctx, player(f5))
+		w.checkPlayer(ctx, player(f5East))
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	// tick advances the clock by d, runs punishPlayers once and waits until the queued actions were sent.
+	tick := func(d time.Duration) {
+		clock.Advance(d)
+		w.punishTick(ctx)
+		Eventually(w.actions.len).Should(BeZero())
+	}
+
+	It("ignores the first position of a player", func() {
+		w.checkPlayer(ctx, api.GetPlayerResponse{Id: "2", Name: "Baker", Team: api.PlayerTeamUs, Position: e5})
+		tick(0)
+		Consistently(server.Commands, 50*time.Millisecond).Should(BeEmpty())
+	})
+
+	It("warns and punishes a player outside of the fences", func() {
+		w.checkPlayer(ctx, player(e5))
+		tick(0)
+		Eventually(server.Commands).Should(Equal([]command{{Name: "message", PlayerId: "Able", Message: "warning 10s"}}))
+
+		tick(5 * time.Second)
+		Consistently(server.Commands, 50*time.Millisecond).Should(HaveLen(1))
+
+		tick(6 * time.Second)
+		Eventually(server.Commands).Should(ContainElement(command{Name: "punish", PlayerId: "1", Message: "punish 10s"}))
+
+		// the player is punished once only, and forgotten after the punishment
+		tick(time.Second)
+		clock.Advance(5 * time.Second)
+		Eventually(func() int { return w.Status().PlayersOutside }).Should(Equal(0))
+		Expect(server.Commands()).To(HaveLen(2))
+	})
+
+	It("records the warnings and punishments", func() {
+		start := clock.Now()
+		w.checkPlayer(ctx, player(e5))
+		tick(0)
+		tick(11 * time.Second)
+		event := history.Event{
+			Type:       history.EventWarning,
+			Time:       start,
+			Server:     "127.0.0.1:7779",
+			MapName:    "CARENTAN",
+			GameMode:   "Warfare",
+			PlayerID:   "1",
+			PlayerName: "Able",
+			Team:       "Allies",
+			Grid:       "E5 Numpad 5",
+			Position:   history.Position{X: e5.X, Y: e5.Y, Z: e5.Z},
+		}
+		punish := event
+		punish.Type, punish.Time = history.EventPunish, start.Add(11*time.Second)
+		Eventually(recorder.Events).Should(Equal([]history.Event{event, punish}))
+	})
+
+	It("does not punish a player returning into the fences", func() {
+		w.checkPlayer(ctx, player(e5))
+		tick(0)
+		tick(5 * time.Second)
+		w.checkPlayer(ctx, player(f5))
+		tick(10 * time.Second)
+		Eventually(server.Commands).Should(HaveLen(1))
+		Consistently(server.Commands, 50*time.Millisecond).Should(Equal([]command{{Name: "message", PlayerId: "Able", Message: "warning 10s"}}))
+		Expect(w.Status().PlayersOutside).To(Equal(0))
+		Expect(recorder.Events()).To(ConsistOf(
+			HaveField("Type", history.EventWarning),
+			And(HaveField("Type", history.EventBackInside), HaveField("Grid", "F5 Numpad 5"), HaveField("Position.X", f5.X)),
+		))
+	})
+
+	Context("with a back inside message", func() {
+		BeforeEach(func() {
+			config.Messages.BackInside = Pointer("welcome back")
+		})
+
+		It("tells the player about being back inside", func() {
+			w.checkPlayer(ctx, player(e5))
+			tick(0)
+			w.checkPlayer(ctx, player(f5))
+			tick(time.Second)
+			Eventually(server.Commands).Should(ContainElement(command{Name: "message", PlayerId: "Able", Message: "welcome back"}))
+		})
+	})
+
+	Context("with a warning schedule", func() {
+		BeforeEach(func() {
+			config.Warnings = []data.Warning{{AfterSeconds: 0}, {AfterSeconds: 5}, {AfterSeconds: 8, Message: Pointer("last warning %s")}}
+		})
+
+		It("sends each warning with the remaining time", func() {
+			w.checkPlayer(ctx, player(e5))
+			for i := 0; i <= 9; i++ {
+				tick(time.Second)
+			}
+			Eventually(server.Commands).Should(ConsistOf(
+				command{Name: "message", PlayerId: "Able", Message: "warning 9s"},
+				command{Name: "message", PlayerId: "Able", Message: "warning 5s"},
+				command{Name: "message", PlayerId: "Able", Message: "last warning 2s"},
+			))
+		})
+	})
+
+	Context("with an escalation", func() {
+		BeforeEach(func() {
+			config.Escalation = &data.Escalation{Steps: []data.EscalationStep{{Violations: 2, Action: data.ActionKick}}}
+		})
+
+		It("kicks repeat offenders, also after a map change", func() {
+			w.checkPlayer(ctx, player(e5))
+			tick(0)
+			tick(11 * time.Second)
+			Eventually(server.Commands).Should(ContainElement(HaveField("Name", "punish")))
+			clock.Advance(5 * time.Second)
+			Eventually(func() int { return w.Status().PlayersOutside }).Should(Equal(0))
+
+			server.SetMap("FOY", "Warfare")
+			Expect(w.populateSession(ctx)).To(Succeed())
+			server.SetMap("CARENTAN", "Warfare")
+			Expect(w.populateSession(ctx)).To(Succeed())
+
+			w.checkPlayer(ctx, player(f5))
+			w.checkPlayer(ctx, player(e5))
+			tick(0)
+			tick(11 * time.Second)
+			Eventually(server.Commands).Should(ContainElement(HaveField("Name", "kick")))
+			Eventually(recorder.Events).Should(ContainElement(And(
+				HaveField("Type", history.EventKick),
+				HaveField("MapName", "CARENTAN"),
+				HaveField("Team", "Allies"),
+				HaveField("Grid", "E5 Numpad 5"),
+			)))
+			Expect(recorder.Events()).To(ContainElement(HaveField("Type", history.EventPunish)))
+		})
+	})
+
+	Context("with the Afrika Korps", func() {
+		// baker is a player of the Afrika Korps moving from f5 to e5
+		baker := func(p api.WorldPosition) api.GetPlayerResponse {
+			return api.GetPlayerResponse{Id: "2", Name: "Baker", Team: api.PlayerTeamDak, Position: p}
+		}
+
+		BeforeEach(func() {
+			config.AxisFence = []data.Fence{{X: Pointer("F")}}
+		})
+
+		It("checks the players against the fences of the Axis", func() {
+			w.checkPlayer(ctx, baker(f5))
+			w.checkPlayer(ctx, baker(e5))
+			tick(0)
+			Expect(server.Commands()).To(Equal([]command{{Name: "message", PlayerId: "Baker", Message: "warning 10s"}}))
+			o, _ := w.outsidePlayers.Load("2")
+			Expect(o.Side).To(Equal(data.SideAxis))
+		})
+
+		Context("with fences of the faction", func() {
+			BeforeEach(func() {
+				config.DAKFence = []data.Fence{{X: Pointer("E")}}
+			})
+
+			It("checks the players against the fences of the faction", func() {
+				w.checkPlayer(ctx, baker(f5))
+				w.checkPlayer(ctx, baker(e5))
+				tick(0)
+				Expect(server.Commands()).To(BeEmpty())
+				Expect(w.Status().PlayersOutside).To(BeZero())
+			})
+
+			It("counts the fences of the faction for its side", func() {
+				Expect(w.Status().AxisFences).To(Equal(2))
+				Expect(w.Status().AlliesFences).To(Equal(1))
+			})
+		})
+
+		Context("with the faction on the Allies side", func() {
+			BeforeEach(func() {
+				config.AlliesFence = []data.Fence{{X: Pointer("E")}, {X: Pointer("F")}}
+				config.Factions = map[data.Faction]data.Side{data.FactionDAK: data.SideAllies}
+			})
+
+			It("checks the players against the fences of the Allies", func() {
+				w.checkPlayer(ctx, baker(f5))
+				w.checkPlayer(ctx, baker(e5))
+				tick(0)
+				Expect(server.Commands()).To(BeEmpty())
+			})
+
+			It("counts the fences shared by the factions of a side once", func() {
+				Expect(w.Status().AxisFences).To(Equal(1))
+				Expect(w.Status().AlliesFences).To(Equal(2))
+			})
+		})
+	})
+
+	Context("in an Offensive", func() {
+		BeforeEach(func() {
+			server.SetMap("CARENTAN", "Offensive")
+			config.AttackerFence = []data.Fence{{X: Pointer("D")}, {X: Pointer("E")}}
+		})
+
+		It("checks the attackers against the fences of the attackers", func() {
+			// Able moved from F5 to F5 east in JustBeforeEach, which is outside of the fences of the attackers
+			Expect(w.Status().PlayersOutside).To(Equal(1))
+			w.checkPlayer(ctx, player(e5))
+			Expect(w.Status().PlayersOutside).To(BeZero())
+		})
+
+		It("logs the attackers when an Offensive starts", func() {
+			var logs bytes.Buffer
+			w.l = slog.New(slog.NewTextHandler(&logs, nil))
+			server.SetMap("FOY", "Offensive")
+			Expect(w.populateSession(ctx)).To(Succeed())
+			Expect(w.populateSession(ctx)).To(Succeed())
+			config.OffensiveAttackers = map[string]data.Side{"STALINGRAD": data.SideAxis}
+			w.Update(config)
+			server.SetMap("STALINGRAD", "Offensive")
+			Expect(w.populateSession(ctx)).To(Succeed())
+			Expect(strings.Count(logs.String(), "msg=offensive-attacker-assumed map=FOY attacker=Allies")).To(Equal(1))
+			Expect(logs.String()).To(ContainSubstring("msg=offensive-attacker map=STALINGRAD attacker=Axis"))
+		})
+
+		It("counts the fences of the attackers for their side", func() {
+			Expect(w.Status().AlliesFences).To(Equal(2))
+			Expect(w.Status().AxisFences).To(BeZero())
+		})
+	})
+
+	Context("with a fence depending on the players of a side", func() {
+		BeforeEach(func() {
+			config.AlliesFence = []data.Fence{{X: Pointer("F"), Condition: &data.Condition{GreaterThan: map[string]int{"allies_player_count": 1}}}}
+		})
+
+		It("applies the fence once the players were polled", func() {
+			Expect(w.Status().AlliesFences).To(BeZero())
+			server.SetPlayers(player(f5), api.GetPlayerResponse{Id: "2", Name: "Baker", Team: api.PlayerTeamGb, Position: f5})
+			go w.pollPlayers(ctx, clock.NewTicker(time.Second))
+			clock.Advance(time.Second)
+			Eventually(func() int { return w.Status().AlliesFences }).Should(Equal(1))
+		})
+	})
+
+	Context("with a fence depending on the time", func() {
+		BeforeEach(func() {
+			// the clock starts at 20:00 UTC, which is 22:00 in Berlin
+			config.TimeZone = "Europe/Berlin"
+			config.AlliesFence = []data.Fence{{X: Pointer("F"), Condition: &data.Condition{Schedule: []data.TimeWindow{{From: "22:00", To: "23:00"}}}}}
+		})
+
+		It("re-evaluates the fence with each session poll", func() {
+			Expect(w.Status().AlliesFences).To(Equal(1))
+			clock.Advance(time.Hour)
+			Expect(w.populateSession(ctx)).To(Succeed())
+			Expect(w.Status().AlliesFences).To(BeZero())
+		})
+	})
+
+	Context("with a fence depending on the player count", func() {
+		// poll sets the player count of the server to n and polls the session
+		poll := func(n int) {
+			server.SetPlayers(make([]api.GetPlayerResponse, n)...)
+			Expect(w.populateSession(ctx)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			config.AlliesFence = []data.Fence{{X: Pointer("F"), Condition: &data.Condition{LessThan: map[string]int{"player_count": 10}}}}
+		})
+
+		It("switches the fence at the threshold without hysteresis", func() {
+			poll(10)
+			Expect(w.Status().AlliesFences).To(BeZero())
+			poll(9)
+			Expect(w.Status().AlliesFences).To(Equal(1))
+		})
+
+		Context("and a band", func() {
+			BeforeEach(func() {
+				config.Hysteresis = &data.Hysteresis{Band: 3}
+			})
+
+			It("keeps the fence until the player count is beyond the band", func() {
+				poll(12)
+				Expect(w.Status().AlliesFences).To(Equal(1))
+				poll(13)
+				Expect(w.Status().AlliesFences).To(BeZero())
+				poll(11)
+				Expect(w.Status().AlliesFences).To(BeZero())
+				poll(9)
+				Expect(w.Status().AlliesFences).To(Equal(1))
+			})
+		})
+
+		Context("and a hold time", func() {
+			BeforeEach(func() {
+				config.Hysteresis = &data.Hysteresis{HoldSeconds: 60}
+			})
+
+			It("keeps the fences for the hold time", func() {
+				poll(10)
+				Expect(w.Status().AlliesFences).To(Equal(1))
+				clock.Advance(time.Minute)
+				poll(10)
+				Expect(w.Status().AlliesFences).To(BeZero())
+				clock.Advance(30 * time.Second)
+				poll(0)
+				Expect(w.Status().AlliesFences).To(BeZero())
+			})
+
+			It("does not hold the fences of another map", func() {
+				server.SetMap("FOY", "Warfare")
+				poll(10)
+				Expect(w.Status().AlliesFences).To(BeZero())
+			})
+
+			It("does not hold the fences of a reloaded config", func() {
+				reloaded := config
+				reloaded.AlliesFence = []data.Fence{{X: Pointer("G"), Condition: &data.Condition{LessThan: map[string]int{"player_count": 5}}}}
+				w.Update(reloaded)
+				poll(10)
+				Expect(w.Status().AlliesFences).To(BeZero())
+			})
+		})
+
+		Context("and smoothing", func() {
+			BeforeEach(func() {
+				config.Hysteresis = &data.Hysteresis{Samples: 3}
+			})
+
+			It("uses the average of the latest player counts", func() {
+				poll(12)
+				Expect(w.Status().AlliesFences).To(Equal(1))
+				Expect(w.Status().PlayerCount).To(Equal(12))
+				poll(12)
+				Expect(w.Status().AlliesFences).To(Equal(1))
+				poll(12)
+				Expect(w.Status().AlliesFences).To(BeZero())
+			})
+		})
+	})
+
+	It("logs players of unknown teams", func() {
+		var logs bytes.Buffer
+		w.l = slog.New(slog.NewTextHandler(&logs, nil))
+		unknown := api.GetPlayerResponse{Id: "2", Name: "Baker", Team: 6, Position: f5}
+		w.checkPlayer(ctx, unknown)
+		unknown.Position = e5
+		w.checkPlayer(ctx, unknown)
+		w.checkPlayer(ctx, unknown)
+		tick(0)
+		Expect(server.Commands()).To(BeEmpty())
+		Expect(strings.Count(logs.String(), "unresolved-team")).To(Equal(1))
+		Expect(logs.String()).To(ContainSubstring("player=Baker team=6"))
+	})
+
+	Context("with a failing server", func() {
+		failure := errors.New("broken pipe")
+
+		JustBeforeEach(func() {
+			server.SetError(failure)
+		})
+
+		It("reports the failed session poll and keeps the last session", func() {
+			Expect(w.populateSession(ctx)).To(MatchError(failure))
+			Expect(w.Status().MapName).To(Equal("CARENTAN"))
+			Expect(w.Health()).To(And(HaveField("ConsecutiveErrors", 1), HaveField("LastError", "broken pipe")))
+		})
+
+		It("reports the failed player poll", func() {
+			go w.pollPlayers(ctx, clock.NewTicker(time.Second))
+			clock.Advance(time.Second)
+			Eventually(func() Health { return w.Health() }).Should(HaveField("ConsecutiveErrors", 1))
+			Expect(w.Health().LastError).To(Equal("broken pipe"))
+		})
+	})
+
+	It("forgets players outside of the fences on map change", func() {
+		w.checkPlayer(ctx, player(e5))
+		Expect(w.Status().PlayersOutside).To(Equal(1))
+
+		server.SetMap("FOY", "Warfare")
+		Expect(w.populateSession(ctx)).To(Succeed())
+		Expect(w.Status().PlayersOutside).To(Equal(0))
+	})
+
+	It("uses the grace period of the fence the player left", func() {
+		w.Update(data.Server{
+			Host:        config.Host,
+			Port:        config.Port,
+			AlliesFence: []data.Fence{{X: Pointer("F"), Settings: data.Settings{PunishAfterSeconds: Pointer(3)}}},
+			Messages:    config.Messages,
+		})
+		Expect(w.populateSession(ctx)).To(Succeed())
+		w.checkPlayer(ctx, player(f5))
+		w.checkPlayer(ctx, player(e5))
+		tick(0)
+		tick(4 * time.Second)
+		Eventually(server.Commands).Should(ConsistOf(
+			command{Name: "message", PlayerId: "Able", Message: "warning 3s"},
+			command{Name: "punish", PlayerId: "1", Message: "punish 3s"},
+		))
+	})
+})
+
+func Pointer[T any](v T) *T {
+	return &v
+}